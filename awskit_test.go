@@ -2,11 +2,26 @@ package aws
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,3 +37,319 @@ func loadConfig(t *testing.T) aws.Config {
 	}
 	return cfg
 }
+
+// fakeS3 serves objects, listing, copying and multipart uploads of a single bucket in path style
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]*fakeS3Object
+	uploads  map[string]*fakeS3Upload
+	sequence int
+
+	// sse is returned as server side encryption of objects
+	sse string
+
+	// versioned makes every write create a new version
+	versioned bool
+
+	// hook handles the request instead if it returns true
+	hook func(w http.ResponseWriter, r *http.Request) bool
+
+	// requests records "METHOD key?query" of requests
+	requests []string
+}
+
+type fakeS3Object struct {
+	data     []byte
+	header   http.Header
+	etag     string
+	version  string
+	modified time.Time
+}
+
+type fakeS3Upload struct {
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+func newFakeS3Bucket(t *testing.T, fake *fakeS3) *S3Bucket {
+	fake.objects = make(map[string]*fakeS3Object)
+	fake.uploads = make(map[string]*fakeS3Upload)
+	// TLS allows unseekable bodies as the payload isn't hashed
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		UsePathStyle: true,
+		HTTPClient:   server.Client(),
+	})
+	return NewS3Bucket("test", client)
+}
+
+// requestsWithPrefix returns recorded requests starting with prefix
+func (f *fakeS3) requestsWithPrefix(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var l []string
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, prefix) {
+			l = append(l, r)
+		}
+	}
+	return l
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.hook != nil && f.hook(w, r) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	f.requests = append(f.requests, r.Method+" "+key+"?"+r.URL.RawQuery)
+	// writes of objects, including copies and completions of multipart uploads, can be conditional
+	if r.Header.Get("If-None-Match") == "*" && f.objects[key] != nil && !query.Has("partNumber") {
+		writeFakeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query.Get("prefix"), query.Get("delimiter"))
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		f.deleteObjects(w, body)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.sequence++
+		id := fmt.Sprintf("upload-%d", f.sequence)
+		f.uploads[id] = &fakeS3Upload{key: key, header: objectHeader(r.Header), parts: map[int][]byte{}}
+		writeFakeXML(w, "InitiateMultipartUploadResult", fmt.Sprintf("<Bucket>test</Bucket><Key>%s</Key><UploadId>%s</UploadId>", key, id))
+	case query.Has("uploadId"):
+		f.serveUpload(w, r, key, query, body)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+		_, sourceKey, _ := strings.Cut(source, "/")
+		src := f.objects[sourceKey]
+		if src == nil {
+			writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if match := r.Header.Get("X-Amz-Copy-Source-If-Match"); match != "" && match != src.etag {
+			writeFakeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		header := src.header
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			header = objectHeader(r.Header)
+		}
+		obj := f.put(w, key, src.data, header)
+		writeFakeXML(w, "CopyObjectResult", fmt.Sprintf("<ETag>%s</ETag><LastModified>%s</LastModified>",
+			obj.etag, obj.modified.UTC().Format(time.RFC3339)))
+	case r.Method == http.MethodPut:
+		f.put(w, key, body, objectHeader(r.Header))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		f.get(w, r, key)
+	case r.Method == http.MethodDelete:
+		obj := f.objects[key]
+		if obj != nil && (!query.Has("versionId") || query.Get("versionId") == obj.version) {
+			delete(f.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported "+r.Method, http.StatusBadRequest)
+	}
+}
+
+func (f *fakeS3) put(w http.ResponseWriter, key string, data []byte, header http.Header) *fakeS3Object {
+	sum := md5.Sum(data)
+	obj := &fakeS3Object{
+		data:     data,
+		header:   header,
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		modified: time.Now(),
+	}
+	if f.versioned {
+		f.sequence++
+		obj.version = fmt.Sprintf("v%d", f.sequence)
+		w.Header().Set("X-Amz-Version-Id", obj.version)
+	}
+	if f.sse != "" {
+		w.Header().Set("X-Amz-Server-Side-Encryption", f.sse)
+	}
+	f.objects[key] = obj
+	w.Header().Set("ETag", obj.etag)
+	return obj
+}
+
+func (f *fakeS3) get(w http.ResponseWriter, r *http.Request, key string) {
+	obj := f.objects[key]
+	if obj == nil {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && match != obj.etag {
+		writeFakeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	for k, v := range obj.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+	if obj.version != "" {
+		w.Header().Set("X-Amz-Version-Id", obj.version)
+	}
+	if f.sse != "" {
+		w.Header().Set("X-Amz-Server-Side-Encryption", f.sse)
+	}
+	data := obj.data
+	status := http.StatusOK
+	var start, end int
+	if n, _ := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); n == 2 {
+		end = min(end, len(data)-1)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, delimiter string) {
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	prefixes := map[string]bool{}
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if !prefixes[p] {
+					prefixes[p] = true
+					fmt.Fprintf(&b, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", html.EscapeString(p))
+				}
+				continue
+			}
+		}
+		obj := f.objects[key]
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size><ETag>%s</ETag><LastModified>%s</LastModified></Contents>",
+			html.EscapeString(key), len(obj.data), html.EscapeString(obj.etag), obj.modified.UTC().Format(time.RFC3339))
+	}
+	writeFakeXML(w, "ListBucketResult", fmt.Sprintf("<Name>test</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated>%s",
+		html.EscapeString(prefix), len(keys), b.String()))
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, body []byte) {
+	var input struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var b strings.Builder
+	for _, o := range input.Objects {
+		delete(f.objects, o.Key)
+		fmt.Fprintf(&b, "<Deleted><Key>%s</Key></Deleted>", html.EscapeString(o.Key))
+	}
+	writeFakeXML(w, "DeleteResult", b.String())
+}
+
+func (f *fakeS3) serveUpload(w http.ResponseWriter, r *http.Request, key string, query url.Values, body []byte) {
+	upload := f.uploads[query.Get("uploadId")]
+	if upload == nil || upload.key != key {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		upload.parts[number] = body
+		sum := md5.Sum(body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case http.MethodGet:
+		numbers := make([]int, 0, len(upload.parts))
+		for number := range upload.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var b strings.Builder
+		for _, number := range numbers {
+			sum := md5.Sum(upload.parts[number])
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>&quot;%x&quot;</ETag><Size>%d</Size></Part>",
+				number, sum, len(upload.parts[number]))
+		}
+		writeFakeXML(w, "ListPartsResult", fmt.Sprintf("<Bucket>test</Bucket><Key>%s</Key><UploadId>%s</UploadId><IsTruncated>false</IsTruncated>%s",
+			html.EscapeString(key), query.Get("uploadId"), b.String()))
+	case http.MethodPost:
+		var input struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, p := range input.Parts {
+			part, ok := upload.parts[p.PartNumber]
+			if !ok {
+				writeFakeS3Error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			data = append(data, part...)
+		}
+		delete(f.uploads, query.Get("uploadId"))
+		obj := f.put(w, key, data, upload.header)
+		obj.etag = fmt.Sprintf(`"%s-%d"`, strings.Trim(obj.etag, `"`), len(input.Parts))
+		writeFakeXML(w, "CompleteMultipartUploadResult", fmt.Sprintf("<Bucket>test</Bucket><Key>%s</Key><ETag>%s</ETag>",
+			html.EscapeString(key), html.EscapeString(obj.etag)))
+	case http.MethodDelete:
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// objectHeader returns headers of h stored with an object
+func objectHeader(h http.Header) http.Header {
+	header := http.Header{}
+	for k, v := range h {
+		switch k {
+		case "Content-Type", "Content-Encoding", "Content-Disposition", "Content-Language", "Cache-Control":
+			header[k] = v
+		default:
+			if strings.HasPrefix(k, "X-Amz-Meta-") {
+				header[k] = v
+			}
+		}
+	}
+	return header
+}
+
+func writeFakeXML(w http.ResponseWriter, name, content string) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = fmt.Fprintf(w, "<%s>%s</%s>", name, content, name)
+}
+
+func writeFakeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BlobInfo is the metadata of a blob
+type BlobInfo = S3ObjectInfo
+
+// BlobStore is implemented by S3Bucket, LocalBlobStore and MemoryBlobStore,
+// so that code depending on it can run without S3.
+//
+// All implementations follow S3 semantics:
+//   - ErrKeyNotFound is returned when reading a missing key, deleting a missing key is not an error
+//   - ETag of a blob is the quoted hex MD5 of its content unless it's uploaded in parts
+//   - Metadata keys are lower case
+//   - List returns keys in lexicographical order, keys containing delimiter after prefix are grouped into common prefixes
+type BlobStore interface {
+	PutBlob(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (string, error)
+	GetBlob(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	StatBlob(ctx context.Context, key string) (*BlobInfo, error)
+	DeleteBlob(ctx context.Context, key string) error
+	List(ctx context.Context, prefix, delimiter string) ([]*BlobInfo, []string, error)
+
+	// BlobURL returns a url to read the blob, which expires after ttl if the store supports it
+	BlobURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+var (
+	_ BlobStore = (*S3Bucket)(nil)
+	_ BlobStore = (*LocalBlobStore)(nil)
+	_ BlobStore = (*MemoryBlobStore)(nil)
+)
+
+// PutBlob uploads content read from r. Content larger than 5MB is uploaded in parts.
+func (s *S3Bucket) PutBlob(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (string, error) {
+	return s.PutStream(ctx, key, r, -1, contentType, metadata)
+}
+
+func (s *S3Bucket) GetBlob(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	return s.GetStream(ctx, key)
+}
+
+func (s *S3Bucket) StatBlob(ctx context.Context, key string) (*BlobInfo, error) {
+	return s.Stat(ctx, key)
+}
+
+func (s *S3Bucket) DeleteBlob(ctx context.Context, key string) error {
+	return s.Delete(ctx, key)
+}
+
+func (s *S3Bucket) BlobURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s.PreSignGet(ctx, key, ttl)
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func etagOf(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func lowerMetadataKeys(metadata map[string]string) map[string]string {
+	m := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		m[strings.ToLower(k)] = v
+	}
+	return m
+}
+
+// groupBlobs sorts blobs under prefix, and groups keys containing delimiter into common prefixes
+func groupBlobs(blobs []*BlobInfo, prefix, delimiter string) ([]*BlobInfo, []string) {
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Key < blobs[j].Key
+	})
+	var (
+		objects  []*BlobInfo
+		prefixes []string
+	)
+	for _, b := range blobs {
+		if !strings.HasPrefix(b.Key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(b.Key[len(prefix):], delimiter); i >= 0 {
+				p := b.Key[:len(prefix)+i+len(delimiter)]
+				if len(prefixes) == 0 || prefixes[len(prefixes)-1] != p {
+					prefixes = append(prefixes, p)
+				}
+				continue
+			}
+		}
+		objects = append(objects, b)
+	}
+	return objects, prefixes
+}
@@ -0,0 +1,249 @@
+package aws
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localMetaDir holds a json file of metadata for each blob, it's hidden from listing
+const localMetaDir = ".blobmeta"
+
+// LocalBlobStore keeps blobs as files in a directory. Keys must be valid fs paths, e.g. "a/b.txt".
+type LocalBlobStore struct {
+	dir string
+}
+
+type localBlobMeta struct {
+	ETag        string            `json:"etag"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("abs: %w", err)
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir: %w", err)
+	}
+	return &LocalBlobStore{dir: dir}, nil
+}
+
+func (l *LocalBlobStore) PutBlob(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (string, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", fmt.Errorf("mkdir: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".blob-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	h := md5.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("write: %w", err)
+	}
+
+	meta := &localBlobMeta{
+		ETag:        `"` + hex.EncodeToString(h.Sum(nil)) + `"`,
+		ContentType: contentTypeOf(key, contentType),
+		Metadata:    lowerMetadataKeys(metadata),
+	}
+	if err = l.writeMeta(key, meta); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), name); err != nil {
+		return "", fmt.Errorf("rename: %w", err)
+	}
+	return meta.ETag, nil
+}
+
+func (l *LocalBlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrKeyNotFound
+		}
+		return nil, nil, fmt.Errorf("open: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("stat: %w", err)
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, nil, ErrKeyNotFound
+	}
+	info, err := l.info(key, fi)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (l *LocalBlobStore) StatBlob(ctx context.Context, key string) (*BlobInfo, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if fi.IsDir() {
+		return nil, ErrKeyNotFound
+	}
+	return l.info(key, fi)
+}
+
+func (l *LocalBlobStore) DeleteBlob(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove: %w", err)
+	}
+	if err = os.Remove(l.metaPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove metadata: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalBlobStore) List(ctx context.Context, prefix, delimiter string) ([]*BlobInfo, []string, error) {
+	var blobs []*BlobInfo
+	err := filepath.WalkDir(l.dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == localMetaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".blob-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		info, err := l.info(key, fi)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, info)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("walk: %w", err)
+	}
+	objects, prefixes := groupBlobs(blobs, prefix, delimiter)
+	return objects, prefixes, nil
+}
+
+// BlobURL returns a "file:" url of the blob, which never expires
+func (l *LocalBlobStore) BlobURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(name)}).String(), nil
+}
+
+func (l *LocalBlobStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." || strings.HasPrefix(key, localMetaDir+"/") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func (l *LocalBlobStore) metaPath(key string) string {
+	return filepath.Join(l.dir, localMetaDir, filepath.FromSlash(key)+".json")
+}
+
+func (l *LocalBlobStore) writeMeta(key string, meta *localBlobMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	name := l.metaPath(key)
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	if err = os.WriteFile(name, data, 0o644); err != nil {
+		return fmt.Errorf("write metadata: %w", err)
+	}
+	return nil
+}
+
+// info reads metadata of the blob. Files created outside the store have no metadata,
+// their ETags are computed from content.
+func (l *LocalBlobStore) info(key string, fi fs.FileInfo) (*BlobInfo, error) {
+	info := &BlobInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime().UTC(),
+	}
+	data, err := os.ReadFile(l.metaPath(key))
+	if err == nil {
+		var meta localBlobMeta
+		if err = json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		info.ETag = meta.ETag
+		info.ContentType = meta.ContentType
+		info.Metadata = lowerMetadataKeys(meta.Metadata)
+		return info, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	info.ETag = etagOf(content)
+	info.ContentType = contentTypeOf(key, "")
+	info.Metadata = map[string]string{}
+	return info, nil
+}
@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MemoryBlobStore keeps blobs in memory. It's safe for concurrent use.
+type MemoryBlobStore struct {
+	mu    sync.RWMutex
+	blobs map[string]*memoryBlob
+}
+
+type memoryBlob struct {
+	content []byte
+	info    *BlobInfo
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{
+		blobs: make(map[string]*memoryBlob),
+	}
+}
+
+func (m *MemoryBlobStore) PutBlob(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string) (string, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+	info := &BlobInfo{
+		Key:          key,
+		Size:         int64(len(content)),
+		ETag:         etagOf(content),
+		ContentType:  contentTypeOf(key, contentType),
+		Metadata:     lowerMetadataKeys(metadata),
+		LastModified: time.Now().UTC(),
+	}
+	m.mu.Lock()
+	m.blobs[key] = &memoryBlob{
+		content: content,
+		info:    info,
+	}
+	m.mu.Unlock()
+	return info.ETag, nil
+}
+
+func (m *MemoryBlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error) {
+	m.mu.RLock()
+	b, ok := m.blobs[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrKeyNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b.content)), copyBlobInfo(b.info), nil
+}
+
+func (m *MemoryBlobStore) StatBlob(ctx context.Context, key string) (*BlobInfo, error) {
+	m.mu.RLock()
+	b, ok := m.blobs[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return copyBlobInfo(b.info), nil
+}
+
+func (m *MemoryBlobStore) DeleteBlob(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.blobs, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryBlobStore) List(ctx context.Context, prefix, delimiter string) ([]*BlobInfo, []string, error) {
+	m.mu.RLock()
+	blobs := make([]*BlobInfo, 0, len(m.blobs))
+	for _, b := range m.blobs {
+		blobs = append(blobs, copyBlobInfo(b.info))
+	}
+	m.mu.RUnlock()
+	objects, prefixes := groupBlobs(blobs, prefix, delimiter)
+	return objects, prefixes, nil
+}
+
+// BlobURL returns a "mem:" url of the blob, which is only meaningful in tests
+func (m *MemoryBlobStore) BlobURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "mem:///" + (&url.URL{Path: key}).EscapedPath(), nil
+}
+
+// copyBlobInfo prevents callers from modifying stored metadata
+func copyBlobInfo(info *BlobInfo) *BlobInfo {
+	c := *info
+	c.Metadata = lowerMetadataKeys(info.Metadata)
+	return &c
+}
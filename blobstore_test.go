@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBlobStore(t *testing.T) {
+	testBlobStore(t, NewMemoryBlobStore())
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	require.NoError(t, err)
+	testBlobStore(t, store)
+}
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+
+	_, err := store.StatBlob(ctx, "missing.txt")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, _, err = store.GetBlob(ctx, "missing.txt")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, store.DeleteBlob(ctx, "missing.txt"))
+
+	etag, err := store.PutBlob(ctx, "docs/a.txt", strings.NewReader(""), "", map[string]string{"Owner": "alice"})
+	require.NoError(t, err)
+	require.Equal(t, `"d41d8cd98f00b204e9800998ecf8427e"`, etag)
+	_, err = store.PutBlob(ctx, "docs/img/b.png", strings.NewReader("png"), "image/png", nil)
+	require.NoError(t, err)
+	_, err = store.PutBlob(ctx, "index.html", strings.NewReader("<html></html>"), "", nil)
+	require.NoError(t, err)
+
+	body, info, err := store.GetBlob(ctx, "docs/a.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	require.Empty(t, data)
+	require.Equal(t, etag, info.ETag)
+	require.Equal(t, map[string]string{"owner": "alice"}, info.Metadata)
+	require.True(t, strings.HasPrefix(info.ContentType, "text/plain"))
+
+	info, err = store.StatBlob(ctx, "docs/img/b.png")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), info.Size)
+	require.Equal(t, "image/png", info.ContentType)
+
+	objects, prefixes, err := store.List(ctx, "docs/", "/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "docs/a.txt", objects[0].Key)
+	require.Equal(t, []string{"docs/img/"}, prefixes)
+
+	objects, prefixes, err = store.List(ctx, "", "")
+	require.NoError(t, err)
+	require.Empty(t, prefixes)
+	require.Len(t, objects, 3)
+	require.Equal(t, "docs/a.txt", objects[0].Key)
+	require.Equal(t, "index.html", objects[2].Key)
+
+	url, err := store.BlobURL(ctx, "index.html", 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, url)
+
+	require.NoError(t, store.DeleteBlob(ctx, "docs/a.txt"))
+	_, err = store.StatBlob(ctx, "docs/a.txt")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,257 @@
+package aws
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudFrontPolicy is a custom policy of signed URLs and cookies
+type CloudFrontPolicy struct {
+	// Resource is the URL which may contain wildcards, e.g. "https://d111111abcdef8.cloudfront.net/videos/*".
+	// SignURLWithPolicy uses the URL being signed if it's empty.
+	Resource string
+
+	Expires time.Time
+
+	// Starts is optional, access is denied before it
+	Starts time.Time
+
+	// IPRange is optional, e.g. "192.0.2.0/24" or "192.0.2.1"
+	IPRange string
+}
+
+// CloudFrontSigner creates CloudFront signed URLs and cookies offline with a key of a trusted key group
+type CloudFrontSigner struct {
+	keyPairID string
+	key       *rsa.PrivateKey
+}
+
+func NewCloudFrontSigner(keyPairID string, key *rsa.PrivateKey) *CloudFrontSigner {
+	return &CloudFrontSigner{
+		keyPairID: keyPairID,
+		key:       key,
+	}
+}
+
+// ParseCloudFrontPrivateKey parses a PEM encoded RSA private key in PKCS #1 or PKCS #8 form
+func ParseCloudFrontPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("x509.ParsePKCS8PrivateKey: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an RSA key", key)
+	}
+	return rsaKey, nil
+}
+
+// SignURL returns rawURL signed with a canned policy which only limits expiry
+func (s *CloudFrontSigner) SignURL(rawURL string, expires time.Time) (string, error) {
+	if _, err := url.Parse(rawURL); err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+	signature, err := s.sign(cannedPolicy(rawURL, expires))
+	if err != nil {
+		return "", err
+	}
+	return appendQuery(rawURL, url.Values{
+		"Expires":     {strconv.FormatInt(expires.Unix(), 10)},
+		"Signature":   {signature},
+		"Key-Pair-Id": {s.keyPairID},
+	}), nil
+}
+
+// SignURLWithPolicy returns rawURL signed with a custom policy
+func (s *CloudFrontSigner) SignURLWithPolicy(rawURL string, policy *CloudFrontPolicy) (string, error) {
+	if _, err := url.Parse(rawURL); err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+	if policy.Resource == "" {
+		p := *policy
+		p.Resource = rawURL
+		policy = &p
+	}
+	encoded, signature, err := s.signPolicy(policy)
+	if err != nil {
+		return "", err
+	}
+	return appendQuery(rawURL, url.Values{
+		"Policy":      {encoded},
+		"Signature":   {signature},
+		"Key-Pair-Id": {s.keyPairID},
+	}), nil
+}
+
+// SignCookies returns cookies granting access to resource with a canned policy. resource can't contain wildcards.
+// Set Domain and Path of cookies before sending them.
+func (s *CloudFrontSigner) SignCookies(resource string, expires time.Time) ([]*http.Cookie, error) {
+	signature, err := s.sign(cannedPolicy(resource, expires))
+	if err != nil {
+		return nil, err
+	}
+	return s.cookies(expires, map[string]string{
+		"CloudFront-Expires":   strconv.FormatInt(expires.Unix(), 10),
+		"CloudFront-Signature": signature,
+	}), nil
+}
+
+// SignCookiesWithPolicy returns cookies granting access to policy.Resource, which usually contains wildcards.
+// Set Domain and Path of cookies before sending them.
+func (s *CloudFrontSigner) SignCookiesWithPolicy(policy *CloudFrontPolicy) ([]*http.Cookie, error) {
+	if policy.Resource == "" {
+		return nil, errors.New("missing resource")
+	}
+	encoded, signature, err := s.signPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	return s.cookies(policy.Expires, map[string]string{
+		"CloudFront-Policy":    encoded,
+		"CloudFront-Signature": signature,
+	}), nil
+}
+
+func (s *CloudFrontSigner) cookies(expires time.Time, values map[string]string) []*http.Cookie {
+	values["CloudFront-Key-Pair-Id"] = s.keyPairID
+	cookies := make([]*http.Cookie, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		cookies = append(cookies, &http.Cookie{
+			Name:     name,
+			Value:    values[name],
+			Path:     "/",
+			Expires:  expires,
+			Secure:   true,
+			HttpOnly: true,
+		})
+	}
+	return cookies
+}
+
+func (s *CloudFrontSigner) signPolicy(policy *CloudFrontPolicy) (string, string, error) {
+	data, err := customPolicy(policy)
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := s.sign(data)
+	if err != nil {
+		return "", "", err
+	}
+	return cloudFrontEncode(data), signature, nil
+}
+
+func (s *CloudFrontSigner) sign(policy []byte) (string, error) {
+	sum := sha1.Sum(policy)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("rsa.SignPKCS1v15: %w", err)
+	}
+	return cloudFrontEncode(signature), nil
+}
+
+// cannedPolicy must be byte-for-byte the policy CloudFront rebuilds from the URL to verify the signature
+func cannedPolicy(resource string, expires time.Time) []byte {
+	return []byte(fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":%d}}}]}`,
+		resource, expires.Unix()))
+}
+
+type cloudFrontEpochTime struct {
+	EpochTime int64 `json:"AWS:EpochTime"`
+}
+
+type cloudFrontSourceIP struct {
+	SourceIP string `json:"AWS:SourceIp"`
+}
+
+type cloudFrontStatement struct {
+	Resource  string `json:"Resource"`
+	Condition struct {
+		DateLessThan    cloudFrontEpochTime  `json:"DateLessThan"`
+		DateGreaterThan *cloudFrontEpochTime `json:"DateGreaterThan,omitempty"`
+		IPAddress       *cloudFrontSourceIP  `json:"IpAddress,omitempty"`
+	} `json:"Condition"`
+}
+
+func customPolicy(policy *CloudFrontPolicy) ([]byte, error) {
+	if policy.Expires.IsZero() {
+		return nil, errors.New("missing expires")
+	}
+	var stmt cloudFrontStatement
+	stmt.Resource = policy.Resource
+	stmt.Condition.DateLessThan.EpochTime = policy.Expires.Unix()
+	if !policy.Starts.IsZero() {
+		if !policy.Starts.Before(policy.Expires) {
+			return nil, errors.New("starts is not before expires")
+		}
+		stmt.Condition.DateGreaterThan = &cloudFrontEpochTime{EpochTime: policy.Starts.Unix()}
+	}
+	if policy.IPRange != "" {
+		ipRange, err := parseIPRange(policy.IPRange)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Condition.IPAddress = &cloudFrontSourceIP{SourceIP: ipRange}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// resources with query strings must not be escaped
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(map[string][]*cloudFrontStatement{"Statement": {&stmt}}); err != nil {
+		return nil, fmt.Errorf("json.Encode: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func parseIPRange(s string) (string, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", fmt.Errorf("invalid ip range %s", s)
+		}
+		if ip.To4() != nil {
+			return s + "/32", nil
+		}
+		return s + "/128", nil
+	}
+	if _, _, err := net.ParseCIDR(s); err != nil {
+		return "", fmt.Errorf("invalid ip range %s", s)
+	}
+	return s, nil
+}
+
+// cloudFrontEncode is base64 with characters invalid in query strings replaced
+func cloudFrontEncode(data []byte) string {
+	return strings.NewReplacer("+", "-", "=", "_", "/", "~").Replace(base64.StdEncoding.EncodeToString(data))
+}
+
+// appendQuery keeps the existing query string as is, because it's part of the signed resource
+func appendQuery(rawURL string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + params.Encode()
+}
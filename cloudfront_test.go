@@ -0,0 +1,103 @@
+package aws
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func cloudFrontDecode(t *testing.T, s string) []byte {
+	data, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(s))
+	require.NoError(t, err)
+	return data
+}
+
+func verifyCloudFrontSignature(t *testing.T, key *rsa.PrivateKey, policy []byte, signature string) {
+	sum := sha1.Sum(policy)
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, sum[:], cloudFrontDecode(t, signature)))
+}
+
+func TestCloudFrontSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err := ParseCloudFrontPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	signer := NewCloudFrontSigner("K2JCJMDEHXQW5F", key)
+	expires := time.Unix(1767225600, 0)
+
+	t.Run("CannedURL", func(t *testing.T) {
+		rawURL := "https://d111111abcdef8.cloudfront.net/image.jpg?size=large"
+		signed, err := signer.SignURL(rawURL, expires)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(signed, rawURL+"&"))
+		u, err := url.Parse(signed)
+		require.NoError(t, err)
+		q := u.Query()
+		require.Equal(t, "1767225600", q.Get("Expires"))
+		require.Equal(t, "K2JCJMDEHXQW5F", q.Get("Key-Pair-Id"))
+		policy := `{"Statement":[{"Resource":"` + rawURL + `","Condition":{"DateLessThan":{"AWS:EpochTime":1767225600}}}]}`
+		verifyCloudFrontSignature(t, key, []byte(policy), q.Get("Signature"))
+	})
+
+	t.Run("CustomURL", func(t *testing.T) {
+		signed, err := signer.SignURLWithPolicy("https://d111111abcdef8.cloudfront.net/videos/a.mp4", &CloudFrontPolicy{
+			Resource: "https://d111111abcdef8.cloudfront.net/videos/*",
+			Expires:  expires,
+			Starts:   expires.Add(-time.Hour),
+			IPRange:  "192.0.2.1",
+		})
+		require.NoError(t, err)
+		u, err := url.Parse(signed)
+		require.NoError(t, err)
+		q := u.Query()
+		require.Empty(t, q.Get("Expires"))
+		policy := cloudFrontDecode(t, q.Get("Policy"))
+		require.JSONEq(t, `{"Statement":[{"Resource":"https://d111111abcdef8.cloudfront.net/videos/*","Condition":{
+			"DateLessThan":{"AWS:EpochTime":1767225600},
+			"DateGreaterThan":{"AWS:EpochTime":1767222000},
+			"IpAddress":{"AWS:SourceIp":"192.0.2.1/32"}}}]}`, string(policy))
+		verifyCloudFrontSignature(t, key, policy, q.Get("Signature"))
+
+		_, err = signer.SignURLWithPolicy("https://d111111abcdef8.cloudfront.net/a.mp4", &CloudFrontPolicy{
+			Expires: expires,
+			IPRange: "192.0.2.300/24",
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Cookies", func(t *testing.T) {
+		cookies, err := signer.SignCookiesWithPolicy(&CloudFrontPolicy{
+			Resource: "https://d111111abcdef8.cloudfront.net/*",
+			Expires:  expires,
+		})
+		require.NoError(t, err)
+		values := make(map[string]string, len(cookies))
+		for _, c := range cookies {
+			require.True(t, c.Secure)
+			values[c.Name] = c.Value
+		}
+		require.Len(t, values, 3)
+		require.Equal(t, "K2JCJMDEHXQW5F", values["CloudFront-Key-Pair-Id"])
+		verifyCloudFrontSignature(t, key, cloudFrontDecode(t, values["CloudFront-Policy"]), values["CloudFront-Signature"])
+
+		cookies, err = signer.SignCookies("https://d111111abcdef8.cloudfront.net/a.jpg", expires)
+		require.NoError(t, err)
+		require.Len(t, cookies, 3)
+		require.Equal(t, "CloudFront-Expires", cookies[0].Name)
+		require.Equal(t, "1767225600", cookies[0].Value)
+	})
+}
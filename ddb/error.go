@@ -0,0 +1,73 @@
+package ddb
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	goaws "go.olapie.com/aws"
+)
+
+// ConditionFailedError is returned when the condition of a write request is not satisfied.
+// errors.Is(err, goaws.ErrConditionFailed) reports true for it.
+type ConditionFailedError struct {
+	// Item is the existing item if dynamodb returned it
+	Item map[string]types.AttributeValue
+
+	err error
+}
+
+func (e *ConditionFailedError) Error() string {
+	return goaws.ErrConditionFailed.Error() + ": " + e.err.Error()
+}
+
+func (e *ConditionFailedError) Unwrap() error {
+	return e.err
+}
+
+func (e *ConditionFailedError) Is(target error) bool {
+	return target == goaws.ErrConditionFailed
+}
+
+func (e *ConditionFailedError) Code() int {
+	return goaws.ErrConditionFailed.Code()
+}
+
+// UnmarshalItem decodes the existing item into v
+func (e *ConditionFailedError) UnmarshalItem(v any) error {
+	if len(e.Item) == 0 {
+		return goaws.ErrItemNotFound
+	}
+	return attributevalue.UnmarshalMap(e.Item, v)
+}
+
+func wrapConditionFailed(err error) error {
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return &ConditionFailedError{
+			Item: ccf.Item,
+			err:  err,
+		}
+	}
+
+	var dup *types.DuplicateItemException
+	if errors.As(err, &dup) {
+		return &ConditionFailedError{
+			err: err,
+		}
+	}
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return &ConditionFailedError{
+					Item: reason.Item,
+					err:  err,
+				}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+	goaws "go.olapie.com/aws"
+)
+
+func TestWrapConditionFailed(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"uid":     &types.AttributeValueMemberS{Value: "u1"},
+		"created": &types.AttributeValueMemberN{Value: "1"},
+		"name":    &types.AttributeValueMemberS{Value: "a"},
+	}
+	for _, test := range []struct {
+		name string
+		err  error
+		item map[string]types.AttributeValue
+	}{
+		{"ConditionalCheckFailed", &types.ConditionalCheckFailedException{Item: item}, item},
+		{"DuplicateItem", &types.DuplicateItemException{}, nil},
+		{"TransactionCanceled", &types.TransactionCanceledException{
+			CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("ConditionalCheckFailed"), Item: item},
+			},
+		}, item},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := wrapConditionFailed(test.err)
+			require.ErrorIs(t, err, goaws.ErrConditionFailed)
+			require.ErrorIs(t, err, test.err)
+			var cfe *ConditionFailedError
+			require.ErrorAs(t, err, &cfe)
+			require.Equal(t, test.item, cfe.Item)
+			require.Equal(t, http.StatusPreconditionFailed, cfe.Code())
+
+			var existing exportTestItem
+			err = cfe.UnmarshalItem(&existing)
+			if test.item == nil {
+				require.ErrorIs(t, err, goaws.ErrItemNotFound)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, exportTestItem{UserID: "u1", Created: 1, Name: "a"}, existing)
+			}
+		})
+	}
+
+	other := errors.New("other")
+	require.Equal(t, other, wrapConditionFailed(other))
+	canceled := &types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{{Code: aws.String("ThrottlingError")}},
+	}
+	require.NotErrorIs(t, wrapConditionFailed(canceled), goaws.ErrConditionFailed)
+}
+
+func TestTable_ConditionFailed(t *testing.T) {
+	ctx := context.Background()
+	item := map[string]any{"uid": map[string]any{"S": "u1"}, "created": map[string]any{"N": "1"}, "name": map[string]any{"S": "a"}}
+	fake := &fakeAWS{responses: map[string][]fakeResponse{
+		"DeleteItem":       {{Status: http.StatusBadRequest, Body: fakeError("ConditionalCheckFailedException", map[string]any{"Item": item})}},
+		"ExecuteStatement": {{Status: http.StatusBadRequest, Body: fakeError("DuplicateItemException", nil)}},
+		"ExecuteTransaction": {{Status: http.StatusBadRequest, Body: fakeError("TransactionCanceledException", map[string]any{
+			"CancellationReasons": []any{map[string]any{"Code": "ConditionalCheckFailed", "Item": item}},
+		})}},
+	}}
+	table, _ := newExportTestTable(t, fake)
+	cond := expression.Name("name").Equal(expression.Value("b"))
+
+	err := table.DeleteIf(ctx, "u1", 1, cond)
+	require.ErrorIs(t, err, goaws.ErrConditionFailed)
+	var cfe *ConditionFailedError
+	require.ErrorAs(t, err, &cfe)
+	var existing exportTestItem
+	require.NoError(t, cfe.UnmarshalItem(&existing))
+	require.Equal(t, "a", existing.Name)
+
+	_, found, err := table.DeleteIfAndReturnOld(ctx, "u1", 1, cond)
+	require.ErrorIs(t, err, goaws.ErrConditionFailed)
+	require.False(t, found)
+
+	err = table.PartiQL().Exec(ctx, `INSERT INTO "users" VALUE {'uid': ?, 'created': ?}`, "u1", 1)
+	require.ErrorIs(t, err, goaws.ErrConditionFailed)
+
+	_, err = table.PartiQL().ExecuteTransaction(ctx, NewStatement(`DELETE FROM "users" WHERE uid = ? AND created = ?`, "u1", 1))
+	require.ErrorIs(t, err, goaws.ErrConditionFailed)
+	require.ErrorAs(t, err, &cfe)
+	require.NotEmpty(t, cfe.Item)
+}
+
+func TestTable_ReturnOld(t *testing.T) {
+	ctx := context.Background()
+	old := map[string]any{"uid": map[string]any{"S": "u1"}, "created": map[string]any{"N": "1"}, "name": map[string]any{"S": "a"}}
+	fake := &fakeAWS{responses: map[string][]fakeResponse{
+		"PutItem":    {{Status: http.StatusOK, Body: map[string]any{"Attributes": old}}, {Status: http.StatusOK, Body: map[string]any{}}},
+		"DeleteItem": {{Status: http.StatusOK, Body: map[string]any{"Attributes": old}}, {Status: http.StatusOK, Body: map[string]any{}}},
+	}}
+	table, _ := newExportTestTable(t, fake)
+
+	item, found, err := table.PutAndReturnOld(ctx, &exportTestItem{UserID: "u1", Created: 1, Name: "b"})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "a", item.Name)
+	_, found, err = table.PutAndReturnOld(ctx, &exportTestItem{UserID: "u2", Created: 1})
+	require.NoError(t, err)
+	require.False(t, found)
+
+	item, found, err = table.DeleteAndReturnOld(ctx, "u1", 1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "a", item.Name)
+	item, found, err = table.DeleteAndReturnOld(ctx, "u3", 1)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Nil(t, item)
+
+	for _, req := range fake.requests {
+		require.Contains(t, string(req.Body), `"ReturnValues":"ALL_OLD"`, req.Operation)
+	}
+}
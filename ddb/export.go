@@ -0,0 +1,225 @@
+package ddb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	goaws "go.olapie.com/aws"
+)
+
+const (
+	maxBatchWriteSize = 25
+	jsonLinesMimeType = "application/x-ndjson"
+)
+
+type ImportOptions struct {
+	// Transform is applied to every item before it's written, e.g. to rewrite keys.
+	// The item is skipped if nil is returned.
+	Transform func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error)
+
+	// ItemsPerSecond throttles writing. Zero means no limit
+	ItemsPerSecond int
+
+	// BatchSize is the number of items of a BatchWriteItem request. It can't be greater than 25
+	BatchSize int
+}
+
+// Export scans the whole table and writes every item to w as a line of JSON.
+// It returns the number of exported items.
+func (t *Table[E, P, S]) Export(ctx context.Context, w io.Writer, format JSONFormat) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(t.tableName),
+		IndexName:              t.indexName,
+		ConsistentRead:         t.consistentRead,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	bw := bufio.NewWriter(w)
+	count := 0
+	paginator := dynamodb.NewScanPaginator(t.client, input)
+	for paginator.HasMorePages() {
+		c := startCall(opScan)
+		output, err := paginator.NextPage(ctx, c.option)
+		t.observe(ctx, c, input, output, err)
+		if err != nil {
+			return count, fmt.Errorf("paginator.NextPage: %w", err)
+		}
+		for _, item := range output.Items {
+			line, err := marshalItemJSON(item, format)
+			if err != nil {
+				return count, fmt.Errorf("marshalItemJSON: %w", err)
+			}
+			line = append(line, '\n')
+			if _, err = bw.Write(line); err != nil {
+				return count, fmt.Errorf("write: %w", err)
+			}
+			count++
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return count, fmt.Errorf("flush: %w", err)
+	}
+	return count, nil
+}
+
+// ExportToS3 streams the table into the object of key in bucket
+func (t *Table[E, P, S]) ExportToS3(ctx context.Context, bucket *goaws.S3Bucket, key string, format JSONFormat) (int, error) {
+	pr, pw := io.Pipe()
+	var (
+		count     int
+		exportErr error
+		done      = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		count, exportErr = t.Export(ctx, pw, format)
+		pw.CloseWithError(exportErr)
+	}()
+
+	_, err := bucket.PutStream(ctx, key, pr, -1, jsonLinesMimeType, nil)
+	// unblock exporting if uploading failed
+	pr.Close()
+	<-done
+	if exportErr != nil && !errors.Is(exportErr, io.ErrClosedPipe) {
+		return count, exportErr
+	}
+	if err != nil {
+		return count, fmt.Errorf("bucket.PutStream: %w", err)
+	}
+	return count, nil
+}
+
+// Import reads JSON lines from r and writes them into the table with batch requests.
+// Empty lines are ignored. It returns the number of imported items.
+func (t *Table[E, P, S]) Import(ctx context.Context, r io.Reader, format JSONFormat, optFns ...func(*ImportOptions)) (int, error) {
+	options := &ImportOptions{
+		BatchSize: maxBatchWriteSize,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.BatchSize <= 0 || options.BatchSize > maxBatchWriteSize {
+		options.BatchSize = maxBatchWriteSize
+	}
+
+	br := bufio.NewReader(r)
+	requests := make([]types.WriteRequest, 0, options.BatchSize)
+	start := time.Now()
+	count := 0
+	lineNo := 0
+
+	flush := func() error {
+		if len(requests) == 0 {
+			return nil
+		}
+		if err := t.batchWriteAll(ctx, requests); err != nil {
+			return err
+		}
+		count += len(requests)
+		requests = requests[:0]
+		if options.ItemsPerSecond > 0 {
+			next := start.Add(time.Duration(count) * time.Second / time.Duration(options.ItemsPerSecond))
+			return sleepContext(ctx, time.Until(next))
+		}
+		return nil
+	}
+
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return count, fmt.Errorf("read: %w", readErr)
+		}
+		lineNo++
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 {
+			item, err := unmarshalItemJSON(line, format)
+			if err != nil {
+				return count, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if options.Transform != nil {
+				item, err = options.Transform(item)
+				if err != nil {
+					return count, fmt.Errorf("line %d: transform: %w", lineNo, err)
+				}
+			}
+			if item != nil {
+				requests = append(requests, types.WriteRequest{
+					PutRequest: &types.PutRequest{Item: item},
+				})
+			}
+			if len(requests) == options.BatchSize {
+				if err = flush(); err != nil {
+					return count, err
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// ImportFromS3 streams the object of key in bucket into the table
+func (t *Table[E, P, S]) ImportFromS3(ctx context.Context, bucket *goaws.S3Bucket, key string, format JSONFormat, optFns ...func(*ImportOptions)) (int, error) {
+	body, _, err := bucket.GetStream(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("bucket.GetStream: %w", err)
+	}
+	defer body.Close()
+	return t.Import(ctx, body, format, optFns...)
+}
+
+// batchWriteAll writes requests and retries unprocessed items until all are done
+func (t *Table[E, P, S]) batchWriteAll(ctx context.Context, requests []types.WriteRequest) error {
+	backoff := 50 * time.Millisecond
+	for len(requests) != 0 {
+		input := &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				t.tableName: requests,
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		}
+		c := startCall(opBatchWriteItem)
+		output, err := t.client.BatchWriteItem(ctx, input, c.option)
+		t.observe(ctx, c, input, output, err)
+		if err != nil {
+			return fmt.Errorf("dynamodb.BatchWriteItem: %w", err)
+		}
+		requests = output.UnprocessedItems[t.tableName]
+		if len(requests) != 0 {
+			if err = sleepContext(ctx, backoff); err != nil {
+				return err
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+		}
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package ddb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+	goaws "go.olapie.com/aws"
+)
+
+type exportTestItem struct {
+	UserID  string `dynamodbav:"uid"`
+	Created int64  `dynamodbav:"created"`
+	Name    string `dynamodbav:"name"`
+}
+
+// fakeAWS serves Scan and BatchWriteItem of a single dynamodb table, and PutObject and GetObject of s3 in path style
+type fakeAWS struct {
+	mu      sync.Mutex
+	items   []json.RawMessage
+	objects map[string][]byte
+
+	// unprocessed makes the first BatchWriteItem return its last item as unprocessed
+	unprocessed bool
+
+	// responses are returned in order for dynamodb operations of the same name, the last one is repeated
+	responses map[string][]fakeResponse
+
+	// requests records bodies of dynamodb requests
+	requests []fakeRequest
+}
+
+type fakeResponse struct {
+	Status int
+	Body   any
+}
+
+type fakeRequest struct {
+	Operation string
+	Body      json.RawMessage
+}
+
+// fakeError is the body of a dynamodb error response
+func fakeError(code string, fields map[string]any) map[string]any {
+	body := map[string]any{"__type": "com.amazonaws.dynamodb.v20120810#" + code, "message": code}
+	for k, v := range fields {
+		body[k] = v
+	}
+	return body
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	target := r.Header.Get("X-Amz-Target")
+	if target != "" {
+		op := target[strings.LastIndex(target, ".")+1:]
+		f.requests = append(f.requests, fakeRequest{Operation: op, Body: body})
+		if responses := f.responses[op]; len(responses) > 0 {
+			resp := responses[0]
+			if len(responses) > 1 {
+				f.responses[op] = responses[1:]
+			}
+			w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+			w.WriteHeader(resp.Status)
+			_ = json.NewEncoder(w).Encode(resp.Body)
+			return
+		}
+	}
+	switch {
+	case strings.HasSuffix(target, ".Scan"):
+		writeFakeJSON(w, map[string]any{"Items": f.items, "Count": len(f.items), "ScannedCount": len(f.items)})
+	case strings.HasSuffix(target, ".BatchWriteItem"):
+		var input struct {
+			RequestItems map[string][]struct {
+				PutRequest struct {
+					Item json.RawMessage
+				}
+			}
+		}
+		if err := json.Unmarshal(body, &input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		unprocessed := map[string]any{}
+		for table, requests := range input.RequestItems {
+			if f.unprocessed && len(requests) > 0 {
+				f.unprocessed = false
+				unprocessed[table] = requests[len(requests)-1:]
+				requests = requests[:len(requests)-1]
+			}
+			for _, req := range requests {
+				f.items = append(f.items, req.PutRequest.Item)
+			}
+		}
+		writeFakeJSON(w, map[string]any{"UnprocessedItems": unprocessed})
+	case target != "":
+		http.Error(w, "unsupported "+target, http.StatusBadRequest)
+	case r.Method == http.MethodPut:
+		f.objects[r.URL.Path] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet:
+		content, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		_, _ = w.Write(content)
+	default:
+		http.Error(w, "unsupported "+r.Method, http.StatusBadRequest)
+	}
+}
+
+func writeFakeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newExportTestTable(t *testing.T, fake *fakeAWS, options ...TableOption[*exportTestItem, string, int64]) (*Table[*exportTestItem, string, int64], *goaws.S3Bucket) {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	db := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) {
+				return 0, nil
+			})
+		}),
+	})
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		UsePathStyle: true,
+	})
+	table := NewTable[*exportTestItem](db, "users", NewPrimaryKeyDefinition[string, int64]("uid", "created"), options...)
+	return table, goaws.NewS3Bucket("backup", client)
+}
+
+func TestTable_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeAWS{objects: map[string][]byte{}}
+	table, _ := newExportTestTable(t, source)
+	for _, item := range []string{
+		`{"uid":{"S":"u1"},"created":{"N":"1"},"name":{"S":"a"}}`,
+		`{"uid":{"S":"u2"},"created":{"N":"2"},"name":{"S":"b"}}`,
+		`{"uid":{"S":"u3"},"created":{"N":"3"},"name":{"S":"c"}}`,
+	} {
+		source.items = append(source.items, json.RawMessage(item))
+	}
+
+	for _, format := range []JSONFormat{DynamoDBJSON, PlainJSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := table.Export(ctx, &buf, format)
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+			require.Equal(t, 3, strings.Count(buf.String(), "\n"))
+
+			target := &fakeAWS{objects: map[string][]byte{}, unprocessed: true}
+			targetTable, _ := newExportTestTable(t, target)
+			n, err = targetTable.Import(ctx, &buf, format, func(options *ImportOptions) {
+				options.BatchSize = 2
+			})
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+			require.Len(t, target.items, 3)
+			for _, item := range source.items {
+				var expected, actual map[string]any
+				require.NoError(t, json.Unmarshal(item, &expected))
+				found := false
+				for _, imported := range target.items {
+					require.NoError(t, json.Unmarshal(imported, &actual))
+					if actual["uid"].(map[string]any)["S"] == expected["uid"].(map[string]any)["S"] {
+						require.Equal(t, expected, actual)
+						found = true
+					}
+				}
+				require.True(t, found)
+			}
+		})
+	}
+}
+
+func TestTable_ExportImportS3(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeAWS{objects: map[string][]byte{}}
+	table, bucket := newExportTestTable(t, fake)
+	fake.items = append(fake.items,
+		json.RawMessage(`{"uid":{"S":"u1"},"created":{"N":"1"},"name":{"S":"a"}}`),
+		json.RawMessage(`{"uid":{"S":"u2"},"created":{"N":"2"},"name":{"S":"b"}}`),
+	)
+
+	n, err := table.ExportToS3(ctx, bucket, "users.jsonl", DynamoDBJSON)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, 2, bytes.Count(fake.objects["/backup/users.jsonl"], []byte("\n")))
+
+	fake.items = nil
+	n, err = table.ImportFromS3(ctx, bucket, "users.jsonl", DynamoDBJSON, func(options *ImportOptions) {
+		options.Transform = func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+			if item["uid"].(*types.AttributeValueMemberS).Value == "u2" {
+				return nil, nil
+			}
+			return item, nil
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Len(t, fake.items, 1)
+
+	_, err = table.ImportFromS3(ctx, bucket, "missing.jsonl", DynamoDBJSON)
+	require.ErrorIs(t, err, goaws.ErrKeyNotFound)
+}
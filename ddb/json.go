@@ -0,0 +1,260 @@
+package ddb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// JSONFormat is the flavour of JSON used to represent an item
+type JSONFormat int
+
+const (
+	// DynamoDBJSON keeps attribute types, e.g. {"Item":{"id":{"S":"1"},"n":{"N":"2"}}}
+	// It's the same format as dynamodb's export to S3.
+	DynamoDBJSON JSONFormat = iota
+
+	// PlainJSON is the ordinary JSON, e.g. {"id":"1","n":2}
+	// Binary is encoded as base64 string and sets as arrays, so they are read back as string and list.
+	PlainJSON
+)
+
+func (f JSONFormat) String() string {
+	switch f {
+	case DynamoDBJSON:
+		return "DynamoDBJSON"
+	case PlainJSON:
+		return "PlainJSON"
+	default:
+		return fmt.Sprintf("JSONFormat(%d)", int(f))
+	}
+}
+
+func marshalItemJSON(item map[string]types.AttributeValue, format JSONFormat) ([]byte, error) {
+	switch format {
+	case DynamoDBJSON:
+		m := make(map[string]any, len(item))
+		for name, av := range item {
+			v, err := toDynamoDBJSONValue(av)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			m[name] = v
+		}
+		return json.Marshal(map[string]any{"Item": m})
+	case PlainJSON:
+		m := make(map[string]any, len(item))
+		for name, av := range item {
+			v, err := toPlainJSONValue(av)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			m[name] = v
+		}
+		return json.Marshal(m)
+	default:
+		return nil, fmt.Errorf("unsupported format %v", format)
+	}
+}
+
+func unmarshalItemJSON(data []byte, format JSONFormat) (map[string]types.AttributeValue, error) {
+	switch format {
+	case DynamoDBJSON:
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		// both {"Item":{...}} and bare {...} are accepted
+		if raw, ok := m["Item"]; ok && len(m) == 1 {
+			m = nil
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal: %w", err)
+			}
+		}
+		item := make(map[string]types.AttributeValue, len(m))
+		for name, raw := range m {
+			av, err := fromDynamoDBJSONValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			item[name] = av
+		}
+		return item, nil
+	case PlainJSON:
+		var m map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&m); err != nil {
+			return nil, fmt.Errorf("json.Decode: %w", err)
+		}
+		item, err := attributevalue.MarshalMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("attributevalue.MarshalMap: %w", err)
+		}
+		return item, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %v", format)
+	}
+}
+
+func toDynamoDBJSONValue(av types.AttributeValue) (map[string]any, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}, nil
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}, nil
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}, nil
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}, nil
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": v.Value}, nil
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}, nil
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}, nil
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}, nil
+	case *types.AttributeValueMemberL:
+		l := make([]any, len(v.Value))
+		for i, e := range v.Value {
+			ev, err := toDynamoDBJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = ev
+		}
+		return map[string]any{"L": l}, nil
+	case *types.AttributeValueMemberM:
+		m := make(map[string]any, len(v.Value))
+		for k, e := range v.Value {
+			ev, err := toDynamoDBJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return map[string]any{"M": m}, nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute value %T", av)
+	}
+}
+
+func fromDynamoDBJSONValue(data json.RawMessage) (types.AttributeValue, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if len(m) != 1 {
+		return nil, fmt.Errorf("expect exactly one type descriptor, got %d", len(m))
+	}
+	for typ, raw := range m {
+		switch typ {
+		case "S":
+			v := new(types.AttributeValueMemberS)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "N":
+			v := new(types.AttributeValueMemberN)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "B":
+			v := new(types.AttributeValueMemberB)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "BOOL":
+			v := new(types.AttributeValueMemberBOOL)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "NULL":
+			v := new(types.AttributeValueMemberNULL)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "SS":
+			v := new(types.AttributeValueMemberSS)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "NS":
+			v := new(types.AttributeValueMemberNS)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "BS":
+			v := new(types.AttributeValueMemberBS)
+			return v, json.Unmarshal(raw, &v.Value)
+		case "L":
+			var l []json.RawMessage
+			if err := json.Unmarshal(raw, &l); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal: %w", err)
+			}
+			v := &types.AttributeValueMemberL{Value: make([]types.AttributeValue, len(l))}
+			for i, e := range l {
+				av, err := fromDynamoDBJSONValue(e)
+				if err != nil {
+					return nil, err
+				}
+				v.Value[i] = av
+			}
+			return v, nil
+		case "M":
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return nil, fmt.Errorf("json.Unmarshal: %w", err)
+			}
+			v := &types.AttributeValueMemberM{Value: make(map[string]types.AttributeValue, len(m))}
+			for k, e := range m {
+				av, err := fromDynamoDBJSONValue(e)
+				if err != nil {
+					return nil, err
+				}
+				v.Value[k] = av
+			}
+			return v, nil
+		default:
+			return nil, fmt.Errorf("unsupported type descriptor %s", typ)
+		}
+	}
+	return nil, nil
+}
+
+func toPlainJSONValue(av types.AttributeValue) (any, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value, nil
+	case *types.AttributeValueMemberN:
+		return json.Number(v.Value), nil
+	case *types.AttributeValueMemberB:
+		return v.Value, nil
+	case *types.AttributeValueMemberBOOL:
+		return v.Value, nil
+	case *types.AttributeValueMemberNULL:
+		return nil, nil
+	case *types.AttributeValueMemberSS:
+		return v.Value, nil
+	case *types.AttributeValueMemberNS:
+		l := make([]json.Number, len(v.Value))
+		for i, n := range v.Value {
+			l[i] = json.Number(n)
+		}
+		return l, nil
+	case *types.AttributeValueMemberBS:
+		return v.Value, nil
+	case *types.AttributeValueMemberL:
+		l := make([]any, len(v.Value))
+		for i, e := range v.Value {
+			ev, err := toPlainJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = ev
+		}
+		return l, nil
+	case *types.AttributeValueMemberM:
+		m := make(map[string]any, len(v.Value))
+		for k, e := range v.Value {
+			ev, err := toPlainJSONValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = ev
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute value %T", av)
+	}
+}
@@ -0,0 +1,45 @@
+package ddb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemJSON(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"id":     &types.AttributeValueMemberS{Value: "u1"},
+		"age":    &types.AttributeValueMemberN{Value: "12345678901234567890"},
+		"ok":     &types.AttributeValueMemberBOOL{Value: true},
+		"null":   &types.AttributeValueMemberNULL{Value: true},
+		"avatar": &types.AttributeValueMemberB{Value: []byte{1, 2, 3}},
+		"tags":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"list": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberN{Value: "1"},
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"k": &types.AttributeValueMemberS{Value: "v"},
+			}},
+		}},
+	}
+
+	t.Run("DynamoDBJSON", func(t *testing.T) {
+		data, err := marshalItemJSON(item, DynamoDBJSON)
+		require.NoError(t, err)
+		decoded, err := unmarshalItemJSON(data, DynamoDBJSON)
+		require.NoError(t, err)
+		require.Equal(t, item, decoded)
+	})
+
+	t.Run("PlainJSON", func(t *testing.T) {
+		data, err := marshalItemJSON(item, PlainJSON)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"age":12345678901234567890`)
+		decoded, err := unmarshalItemJSON(data, PlainJSON)
+		require.NoError(t, err)
+		require.Equal(t, item["id"], decoded["id"])
+		require.Equal(t, item["age"], decoded["age"])
+		require.Equal(t, item["ok"], decoded["ok"])
+		require.Equal(t, item["null"], decoded["null"])
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	goaws "go.olapie.com/aws"
 	"golang.org/x/exp/constraints"
@@ -31,6 +32,10 @@ type PrimaryKeyDefinition[P PartitionKeyConstraint, S SortKeyConstraint] struct
 	prototype     map[string]reflect.Type
 	attrNotExists *string
 	attrExists    *string
+
+	// extractor gets primary key of items of extractorType
+	extractor     func(item any) (P, S, bool)
+	extractorType reflect.Type
 }
 
 func NewPrimaryKeyDefinition[P PartitionKeyConstraint, S SortKeyConstraint](partitionKeyName string, sortKeyName string) *PrimaryKeyDefinition[P, S] {
@@ -56,6 +61,24 @@ func NewPrimaryKeyDefinition[P PartitionKeyConstraint, S SortKeyConstraint](part
 	return d
 }
 
+// WithKeyExtractor returns a copy of d which gets primary keys of items of type E by calling fn rather than encoding them.
+// Items of other types are still encoded with dynamodbav tags.
+func WithKeyExtractor[E any, P PartitionKeyConstraint, S SortKeyConstraint](d *PrimaryKeyDefinition[P, S], fn func(item E) (P, S)) *PrimaryKeyDefinition[P, S] {
+	c := *d
+	c.extractor = func(item any) (P, S, bool) {
+		e, ok := item.(E)
+		if !ok {
+			var p P
+			var s S
+			return p, s, false
+		}
+		p, s := fn(e)
+		return p, s, true
+	}
+	c.extractorType = reflect.TypeOf((*E)(nil)).Elem()
+	return &c
+}
+
 func (d *PrimaryKeyDefinition[P, S]) NewKey(p P, s S) *PrimaryKey[P, S] {
 	return &PrimaryKey[P, S]{
 		PartitionKey: p,
@@ -78,6 +101,103 @@ func (d *PrimaryKeyDefinition[P, S]) NewKeys(partitionKeys []P, sortKeys []S) []
 	return pks
 }
 
+// KeyOf extracts primary key from item's attributes which are encoded with dynamodbav tags,
+// or by the extractor bound with WithKeyExtractor
+func (d *PrimaryKeyDefinition[P, S]) KeyOf(item any) (*PrimaryKey[P, S], error) {
+	if d.extractor != nil {
+		if p, s, ok := d.extractor(item); ok {
+			return d.NewKey(p, s), nil
+		}
+	}
+	attrs, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("attributevalue.MarshalMap: %w", err)
+	}
+	return d.KeyOfAttributes(attrs)
+}
+
+// KeyOfAttributes extracts primary key from item's attributes
+func (d *PrimaryKeyDefinition[P, S]) KeyOfAttributes(attrs map[string]types.AttributeValue) (*PrimaryKey[P, S], error) {
+	pk := &PrimaryKey[P, S]{
+		definition: d,
+	}
+	av, ok := attrs[d.partitionKeyName]
+	if !ok {
+		return nil, fmt.Errorf("missing partition key %s", d.partitionKeyName)
+	}
+	if err := attributevalue.Unmarshal(av, &pk.PartitionKey); err != nil {
+		return nil, fmt.Errorf("unmarshal partition key %s: %w", d.partitionKeyName, err)
+	}
+	if d.HasSortKey() {
+		av, ok = attrs[d.sortKeyName]
+		if !ok {
+			return nil, fmt.Errorf("missing sort key %s", d.sortKeyName)
+		}
+		if err := attributevalue.Unmarshal(av, &pk.SortKey); err != nil {
+			return nil, fmt.Errorf("unmarshal sort key %s: %w", d.sortKeyName, err)
+		}
+	}
+	return pk, nil
+}
+
+// validateItemType returns an error if items of typ don't contain key attributes.
+// Fields are inspected rather than encoded, so that fields with omitempty are found.
+// Types bound to extractors and types with custom marshalers are not checked.
+func (d *PrimaryKeyDefinition[P, S]) validateItemType(typ reflect.Type) error {
+	if typ == nil || typ == d.extractorType {
+		return nil
+	}
+	marshaler := reflect.TypeOf((*attributevalue.Marshaler)(nil)).Elem()
+	for typ.Kind() == reflect.Pointer {
+		if typ.Implements(marshaler) {
+			return nil
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ.Implements(marshaler) || reflect.PointerTo(typ).Implements(marshaler) {
+		return nil
+	}
+
+	attrs := make(map[string]bool)
+	collectAttributeNames(typ, attrs)
+	if !attrs[d.partitionKeyName] {
+		return fmt.Errorf("%v has no partition key attribute %s", typ, d.partitionKeyName)
+	}
+	if d.HasSortKey() && !attrs[d.sortKeyName] {
+		return fmt.Errorf("%v has no sort key attribute %s", typ, d.sortKeyName)
+	}
+	return nil
+}
+
+// collectAttributeNames collects attribute names of struct fields as attributevalue encodes them
+func collectAttributeNames(typ reflect.Type, attrs map[string]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("dynamodbav")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectAttributeNames(ft, attrs)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		attrs[name] = true
+	}
+}
+
 func (d *PrimaryKeyDefinition[P, S]) HasSortKey() bool {
 	if d.sortKeyName == "" {
 		return false
@@ -104,6 +224,21 @@ func (d *PrimaryKeyDefinition[P, S]) Prototype() map[string]reflect.Type {
 }
 
 func (d *PrimaryKeyDefinition[P, S]) DecodeStringToValue(s string) (map[string]types.AttributeValue, error) {
+	return decodeToken(s, d.Prototype())
+}
+
+func (d *PrimaryKeyDefinition[P, S]) EncodeValueToString(v map[string]types.AttributeValue) string {
+	return encodeToken(v)
+}
+
+// encodeToken encodes attributes into a pagination token
+func encodeToken(v map[string]types.AttributeValue) string {
+	data, _ := json.Marshal(v)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeToken decodes a pagination token into attributes, whose names and types must be in prototype
+func decodeToken(s string, prototype map[string]reflect.Type) (map[string]types.AttributeValue, error) {
 	jsonBytes, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
 		return nil, fmt.Errorf("base64.DecodeString: %w", err)
@@ -114,9 +249,9 @@ func (d *PrimaryKeyDefinition[P, S]) DecodeStringToValue(s string) (map[string]t
 		return nil, fmt.Errorf("json.Unmarshal: %w", err)
 	}
 
-	key := make(map[string]types.AttributeValue, len(d.Prototype()))
+	key := make(map[string]types.AttributeValue, len(prototype))
 	for name, val := range nameToValue {
-		typ, ok := d.Prototype()[name]
+		typ, ok := prototype[name]
 		if !ok {
 			return nil, goaws.ErrInvalidToken
 		}
@@ -132,11 +267,6 @@ func (d *PrimaryKeyDefinition[P, S]) DecodeStringToValue(s string) (map[string]t
 	return key, nil
 }
 
-func (d *PrimaryKeyDefinition[P, S]) EncodeValueToString(v map[string]types.AttributeValue) string {
-	data, _ := json.Marshal(v)
-	return base64.StdEncoding.EncodeToString(data)
-}
-
 type PrimaryKey[P PartitionKeyConstraint, S SortKeyConstraint] struct {
 	PartitionKey P
 	SortKey      S
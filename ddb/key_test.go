@@ -0,0 +1,80 @@
+package ddb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type keyTestItem struct {
+	UserID  string `dynamodbav:"uid"`
+	Created int64  `dynamodbav:"created"`
+	Name    string `dynamodbav:"name"`
+}
+
+func TestPrimaryKeyDefinition_KeyOf(t *testing.T) {
+	d := NewPrimaryKeyDefinition[string, int64]("uid", "created")
+	key, err := d.KeyOf(&keyTestItem{UserID: "u1", Created: 10, Name: "n"})
+	require.NoError(t, err)
+	require.Equal(t, "u1", key.PartitionKey)
+	require.Equal(t, int64(10), key.SortKey)
+
+	_, err = NewPrimaryKeyDefinition[string, int64]("id", "created").KeyOf(&keyTestItem{})
+	require.Error(t, err)
+}
+
+func TestNewTable_KeyValidation(t *testing.T) {
+	require.NotPanics(t, func() {
+		NewTable[*keyTestItem](nil, "test", NewPrimaryKeyDefinition[string, int64]("uid", "created"))
+	})
+	require.Panics(t, func() {
+		NewTable[*keyTestItem](nil, "test", NewPrimaryKeyDefinition[string, NoKey]("id", ""))
+	})
+	require.Panics(t, func() {
+		NewTable[*keyTestItem](nil, "test", NewPrimaryKeyDefinition[string, string]("uid", "sk"))
+	})
+}
+
+type omitEmptyKeyTestItem struct {
+	keyTestBase
+	Created int64 `dynamodbav:"created,omitempty"`
+}
+
+type keyTestBase struct {
+	UserID string `dynamodbav:"uid,omitempty"`
+}
+
+type extractorKeyTestItem struct {
+	UserID  string `dynamodbav:"-"`
+	Created int64  `dynamodbav:"-"`
+}
+
+func TestNewTable_KeyValidation_Fields(t *testing.T) {
+	require.NotPanics(t, func() {
+		NewTable[*omitEmptyKeyTestItem](nil, "test", NewPrimaryKeyDefinition[string, int64]("uid", "created"))
+	})
+	require.NotPanics(t, func() {
+		NewIndex[omitEmptyKeyTestItem](nil, "test", "created-index", NewPrimaryKeyDefinition[int64, NoKey]("created", ""))
+	})
+
+	d := NewPrimaryKeyDefinition[string, int64]("uid", "created")
+	require.Panics(t, func() {
+		NewTable[*extractorKeyTestItem](nil, "test", d)
+	})
+	d = WithKeyExtractor(d, func(item *extractorKeyTestItem) (string, int64) {
+		return item.UserID, item.Created
+	})
+	var table *Table[*extractorKeyTestItem, string, int64]
+	require.NotPanics(t, func() {
+		table = NewTable[*extractorKeyTestItem](nil, "test", d)
+	})
+	key, err := table.KeyOf(&extractorKeyTestItem{UserID: "u1", Created: 10})
+	require.NoError(t, err)
+	require.Equal(t, "u1", key.PartitionKey)
+	require.Equal(t, int64(10), key.SortKey)
+
+	// items of other types are encoded
+	key, err = d.KeyOf(&keyTestItem{UserID: "u2", Created: 20})
+	require.NoError(t, err)
+	require.Equal(t, "u2", key.PartitionKey)
+}
@@ -0,0 +1,294 @@
+package ddb
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/middleware"
+	"go.olapie.com/x/xconv"
+	"go.olapie.com/x/xlog"
+)
+
+const (
+	opPutItem        = "PutItem"
+	opGetItem        = "GetItem"
+	opDeleteItem     = "DeleteItem"
+	opQuery          = "Query"
+	opScan           = "Scan"
+	opBatchGetItem   = "BatchGetItem"
+	opBatchWriteItem = "BatchWriteItem"
+
+	opExecuteStatement      = "ExecuteStatement"
+	opBatchExecuteStatement = "BatchExecuteStatement"
+	opExecuteTransaction    = "ExecuteTransaction"
+)
+
+// OperationStats describes a single dynamodb call made by Table or Index
+type OperationStats struct {
+	Operation          string
+	Table              string
+	Index              string
+	ReadCapacityUnits  float64
+	WriteCapacityUnits float64
+	ItemCount          int
+	Retries            int
+	Latency            time.Duration
+	Err                error
+}
+
+// Observer receives stats of every dynamodb call made by Table or Index
+type Observer interface {
+	Observe(ctx context.Context, stats *OperationStats)
+}
+
+type ObserverFunc func(ctx context.Context, stats *OperationStats)
+
+func (f ObserverFunc) Observe(ctx context.Context, stats *OperationStats) {
+	f(ctx, stats)
+}
+
+func WithObserver[E any, P PartitionKeyConstraint, S SortKeyConstraint](o Observer) TableOption[E, P, S] {
+	return func(t *Table[E, P, S]) {
+		t.observers = append(t.observers, o)
+	}
+}
+
+// TableStats is the aggregation of OperationStats of one table
+type TableStats struct {
+	Calls              int64
+	Errors             int64
+	ReadCapacityUnits  float64
+	WriteCapacityUnits float64
+	ItemCount          int64
+	Retries            int64
+	Latency            time.Duration
+}
+
+// StatsObserver aggregates OperationStats per table
+type StatsObserver struct {
+	mu     sync.Mutex
+	tables map[string]*TableStats
+}
+
+var _ Observer = (*StatsObserver)(nil)
+
+func NewStatsObserver() *StatsObserver {
+	return &StatsObserver{
+		tables: make(map[string]*TableStats),
+	}
+}
+
+func (o *StatsObserver) Observe(ctx context.Context, stats *OperationStats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ts := o.tables[stats.Table]
+	if ts == nil {
+		ts = new(TableStats)
+		o.tables[stats.Table] = ts
+	}
+	ts.Calls++
+	if stats.Err != nil {
+		ts.Errors++
+	}
+	ts.ReadCapacityUnits += stats.ReadCapacityUnits
+	ts.WriteCapacityUnits += stats.WriteCapacityUnits
+	ts.ItemCount += int64(stats.ItemCount)
+	ts.Retries += int64(stats.Retries)
+	ts.Latency += stats.Latency
+}
+
+// Stats returns a snapshot of the aggregated stats of table
+func (o *StatsObserver) Stats(table string) TableStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ts := o.tables[table]; ts != nil {
+		return *ts
+	}
+	return TableStats{}
+}
+
+// AllStats returns a snapshot of the aggregated stats of all observed tables
+func (o *StatsObserver) AllStats() map[string]TableStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := make(map[string]TableStats, len(o.tables))
+	for name, ts := range o.tables {
+		m[name] = *ts
+	}
+	return m
+}
+
+func (o *StatsObserver) Reset() {
+	o.mu.Lock()
+	o.tables = make(map[string]*TableStats)
+	o.mu.Unlock()
+}
+
+// NewLogObserver returns an Observer which logs every call with the logger in context.
+// Failed calls are logged at error level.
+func NewLogObserver(level slog.Level) Observer {
+	return ObserverFunc(func(ctx context.Context, stats *OperationStats) {
+		attrs := []slog.Attr{
+			slog.String("operation", stats.Operation),
+			slog.String("table", stats.Table),
+			slog.Float64("rcu", stats.ReadCapacityUnits),
+			slog.Float64("wcu", stats.WriteCapacityUnits),
+			slog.Int("items", stats.ItemCount),
+			slog.Int("retries", stats.Retries),
+			slog.Duration("latency", stats.Latency),
+		}
+		if stats.Index != "" {
+			attrs = append(attrs, slog.String("index", stats.Index))
+		}
+		logger := xlog.FromContext(ctx)
+		if stats.Err != nil {
+			attrs = append(attrs, xlog.Err(stats.Err))
+			logger.LogAttrs(ctx, slog.LevelError, "ddb", attrs...)
+			return
+		}
+		logger.LogAttrs(ctx, level, "ddb", attrs...)
+	})
+}
+
+// call tracks a single dynamodb call made by Table
+type call struct {
+	op       string
+	start    time.Time
+	attempts int
+}
+
+func startCall(op string) *call {
+	return &call{op: op, start: time.Now()}
+}
+
+// option records attempts of the call.
+// Result metadata is read by a middleware as the client drops it together with the output if the call fails.
+func (c *call) option(o *dynamodb.Options) {
+	o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ddbRecordAttempts", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if results, ok := retry.GetAttemptResults(metadata); ok {
+				c.attempts = len(results.Results)
+			}
+			return out, metadata, err
+		}), middleware.Before)
+	})
+}
+
+func (t *Table[E, P, S]) observe(ctx context.Context, c *call, input, output any, err error) {
+	if len(t.observers) == 0 {
+		return
+	}
+
+	stats := &OperationStats{
+		Operation: c.op,
+		Table:     t.tableName,
+		Latency:   time.Since(c.start),
+		Err:       err,
+	}
+	if c.attempts > 1 {
+		stats.Retries = c.attempts - 1
+	}
+	if t.indexName != nil {
+		stats.Index = *t.indexName
+	}
+
+	var consumed []types.ConsumedCapacity
+	switch o := output.(type) {
+	case *dynamodb.PutItemOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			stats.ItemCount = 1
+		}
+	case *dynamodb.DeleteItemOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			stats.ItemCount = 1
+		}
+	case *dynamodb.GetItemOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			if o.Item != nil {
+				stats.ItemCount = 1
+			}
+		}
+	case *dynamodb.QueryOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			stats.ItemCount = int(o.Count)
+		}
+	case *dynamodb.ScanOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			stats.ItemCount = int(o.Count)
+		}
+	case *dynamodb.BatchGetItemOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+			stats.ItemCount = len(o.Responses[t.tableName])
+		}
+	case *dynamodb.ExecuteStatementOutput:
+		if o != nil {
+			consumed = capacityList(o.ConsumedCapacity)
+			stats.ItemCount = len(o.Items)
+		}
+	case *dynamodb.BatchExecuteStatementOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+			stats.ItemCount = len(o.Responses)
+		}
+	case *dynamodb.ExecuteTransactionOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+			stats.ItemCount = len(o.Responses)
+		}
+	case *dynamodb.BatchWriteItemOutput:
+		if o != nil {
+			consumed = o.ConsumedCapacity
+			if in, ok := input.(*dynamodb.BatchWriteItemInput); ok {
+				stats.ItemCount = len(in.RequestItems[t.tableName]) - len(o.UnprocessedItems[t.tableName])
+			}
+		}
+	}
+
+	isRead := c.op == opGetItem || c.op == opQuery || c.op == opScan || c.op == opBatchGetItem
+	if in, ok := input.(*dynamodb.ExecuteStatementInput); ok {
+		isRead = isSelectStatement(aws.ToString(in.Statement))
+	}
+	for _, cc := range consumed {
+		switch {
+		case cc.ReadCapacityUnits != nil || cc.WriteCapacityUnits != nil:
+			stats.ReadCapacityUnits += xconv.Dereference(cc.ReadCapacityUnits)
+			stats.WriteCapacityUnits += xconv.Dereference(cc.WriteCapacityUnits)
+		case isRead:
+			stats.ReadCapacityUnits += xconv.Dereference(cc.CapacityUnits)
+		default:
+			stats.WriteCapacityUnits += xconv.Dereference(cc.CapacityUnits)
+		}
+	}
+
+	for _, o := range t.observers {
+		o.Observe(ctx, stats)
+	}
+}
+
+func capacityList(c *types.ConsumedCapacity) []types.ConsumedCapacity {
+	if c == nil {
+		return nil
+	}
+	return []types.ConsumedCapacity{*c}
+}
+
+func isSelectStatement(sql string) bool {
+	sql = strings.TrimSpace(sql)
+	return len(sql) >= 6 && strings.EqualFold(sql[:6], "SELECT")
+}
@@ -0,0 +1,118 @@
+package ddb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/stretchr/testify/require"
+	"go.olapie.com/x/xlog"
+)
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	item := map[string]any{"uid": map[string]any{"S": "u1"}, "created": map[string]any{"N": "1"}, "name": map[string]any{"S": "a"}}
+	throttled := fakeResponse{Status: http.StatusBadRequest, Body: fakeError("ProvisionedThroughputExceededException", nil)}
+	fake := &fakeAWS{responses: map[string][]fakeResponse{
+		"PutItem": {{Status: http.StatusOK, Body: map[string]any{
+			"ConsumedCapacity": map[string]any{"TableName": "users", "CapacityUnits": 1},
+		}}},
+		"Query": {{Status: http.StatusOK, Body: map[string]any{
+			"Items":            []any{item, item},
+			"Count":            2,
+			"ConsumedCapacity": map[string]any{"TableName": "users", "CapacityUnits": 0.5},
+		}}},
+		"ExecuteStatement": {{Status: http.StatusOK, Body: map[string]any{
+			"ConsumedCapacity": map[string]any{"TableName": "users", "CapacityUnits": 1.5, "ReadCapacityUnits": 0.5, "WriteCapacityUnits": 1},
+		}}},
+		"GetItem": {throttled, {Status: http.StatusOK, Body: map[string]any{
+			"Item":             item,
+			"ConsumedCapacity": map[string]any{"TableName": "users", "CapacityUnits": 0.5},
+		}}},
+		"DeleteItem": {throttled},
+	}}
+	var observed []*OperationStats
+	stats := NewStatsObserver()
+	table, _ := newExportTestTable(t, fake,
+		WithObserver[*exportTestItem, string, int64](stats),
+		WithObserver[*exportTestItem, string, int64](ObserverFunc(func(ctx context.Context, stats *OperationStats) {
+			observed = append(observed, stats)
+		})),
+	)
+
+	require.NoError(t, table.Put(ctx, &exportTestItem{UserID: "u1", Created: 1, Name: "a"}))
+	items, err := table.Query(ctx, "u1", nil)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.NoError(t, table.PartiQL().Exec(ctx, `UPDATE "users" SET name = ? WHERE uid = ? AND created = ?`, "b", "u1", 1))
+	_, err = table.Get(ctx, "u1", 1)
+	require.NoError(t, err)
+	err = table.Delete(ctx, "u1", 1)
+	require.Error(t, err)
+
+	require.Len(t, observed, 5)
+	expected := []OperationStats{
+		{Operation: opPutItem, WriteCapacityUnits: 1, ItemCount: 1},
+		{Operation: opQuery, ReadCapacityUnits: 0.5, ItemCount: 2},
+		{Operation: opExecuteStatement, ReadCapacityUnits: 0.5, WriteCapacityUnits: 1},
+		{Operation: opGetItem, ReadCapacityUnits: 0.5, ItemCount: 1, Retries: 1},
+		{Operation: opDeleteItem, Retries: 2, Err: err},
+	}
+	for i, e := range expected {
+		s := observed[i]
+		require.Equal(t, e.Operation, s.Operation)
+		require.Equal(t, "users", s.Table, s.Operation)
+		require.Equal(t, e.ReadCapacityUnits, s.ReadCapacityUnits, s.Operation)
+		require.Equal(t, e.WriteCapacityUnits, s.WriteCapacityUnits, s.Operation)
+		require.Equal(t, e.ItemCount, s.ItemCount, s.Operation)
+		require.Equal(t, e.Retries, s.Retries, s.Operation)
+		require.Equal(t, e.Err, s.Err, s.Operation)
+		require.Positive(t, s.Latency, s.Operation)
+	}
+	var maxAttempts *retry.MaxAttemptsError
+	require.ErrorAs(t, observed[4].Err, &maxAttempts)
+
+	ts := stats.Stats("users")
+	require.Equal(t, int64(5), ts.Calls)
+	require.Equal(t, int64(1), ts.Errors)
+	require.Equal(t, 1.5, ts.ReadCapacityUnits)
+	require.Equal(t, 2.0, ts.WriteCapacityUnits)
+	require.Equal(t, int64(4), ts.ItemCount)
+	require.Equal(t, int64(3), ts.Retries)
+	require.Equal(t, map[string]TableStats{"users": ts}, stats.AllStats())
+	stats.Reset()
+	require.Equal(t, TableStats{}, stats.Stats("users"))
+}
+
+func TestNewLogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := xlog.NewContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	observer := NewLogObserver(slog.LevelDebug)
+
+	observer.Observe(ctx, &OperationStats{Operation: opQuery, Table: "users", Index: "by_name", ItemCount: 2})
+	require.Empty(t, buf.String())
+
+	observer.Observe(ctx, &OperationStats{Operation: opPutItem, Table: "users", Retries: 2, Err: errors.New("failure")})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, opPutItem, record["operation"])
+	require.Equal(t, "users", record["table"])
+	require.Equal(t, 2.0, record["retries"])
+	require.Equal(t, "failure", record["error"])
+
+	buf.Reset()
+	NewLogObserver(slog.LevelInfo).Observe(ctx, &OperationStats{Operation: opQuery, Table: "users", Index: "by_name", ItemCount: 2})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, "by_name", record["index"])
+	require.Equal(t, 2.0, record["items"])
+}
@@ -0,0 +1,309 @@
+package ddb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	goaws "go.olapie.com/aws"
+)
+
+// Statement is a PartiQL statement with parameters which are Go values
+// E.g. Statement{SQL: `SELECT * FROM "users" WHERE "id" = ?`, Params: []any{id}}
+type Statement struct {
+	SQL    string
+	Params []any
+}
+
+func NewStatement(sql string, params ...any) Statement {
+	return Statement{
+		SQL:    sql,
+		Params: params,
+	}
+}
+
+// BatchStatementResult is the result of a statement in BatchExecute
+type BatchStatementResult[T any] struct {
+	Item  T
+	Found bool
+	Err   error
+}
+
+// PartiQL executes PartiQL statements and decodes items into E
+type PartiQL[E any, P PartitionKeyConstraint, S SortKeyConstraint] struct {
+	table *Table[E, P, S]
+}
+
+func (t *Table[E, P, S]) PartiQL() *PartiQL[E, P, S] {
+	return &PartiQL[E, P, S]{
+		table: t,
+	}
+}
+
+// Execute executes the statement and reads all pages of the result
+func (q *PartiQL[E, P, S]) Execute(ctx context.Context, sql string, params ...any) ([]E, error) {
+	return ExecuteStatementAs[E](ctx, q.table, NewStatement(sql, params...))
+}
+
+// ExecutePage executes the statement and reads one page of the result.
+// nextToken is empty if there are no more pages.
+func (q *PartiQL[E, P, S]) ExecutePage(ctx context.Context, sql string, startToken string, limit int, params ...any) (items []E, nextToken string, err error) {
+	return ExecuteStatementPageAs[E](ctx, q.table, NewStatement(sql, params...), startToken, limit)
+}
+
+// ExecuteOne executes the statement and returns the first item.
+// goaws.ErrItemNotFound is returned if the result is empty.
+func (q *PartiQL[E, P, S]) ExecuteOne(ctx context.Context, sql string, params ...any) (item E, err error) {
+	items, _, err := q.ExecutePage(ctx, sql, "", 1, params...)
+	if err != nil {
+		return item, err
+	}
+	if len(items) == 0 {
+		return item, goaws.ErrItemNotFound
+	}
+	return items[0], nil
+}
+
+// Exec executes a statement which doesn't return items, e.g. INSERT, UPDATE and DELETE
+func (q *PartiQL[E, P, S]) Exec(ctx context.Context, sql string, params ...any) error {
+	input, err := q.table.createExecuteStatementInput(NewStatement(sql, params...), "", 0)
+	if err != nil {
+		return err
+	}
+	_, err = q.table.executeStatement(ctx, input)
+	return err
+}
+
+// BatchExecute executes statements in one batch. Results are in the same order as statements.
+// Failure of a single statement is reported in its result rather than the returned error.
+func (q *PartiQL[E, P, S]) BatchExecute(ctx context.Context, statements ...Statement) ([]*BatchStatementResult[E], error) {
+	return BatchExecuteStatementsAs[E](ctx, q.table, statements...)
+}
+
+// ExecuteTransaction executes statements in a transaction and returns items read by statements.
+// *ConditionFailedError is returned if the transaction is canceled due to a failed condition.
+func (q *PartiQL[E, P, S]) ExecuteTransaction(ctx context.Context, statements ...Statement) ([]E, error) {
+	return ExecuteTransactionAs[E](ctx, q.table, statements...)
+}
+
+// ExecuteStatementAs is the same as PartiQL.Execute but decodes items into T
+func ExecuteStatementAs[T any, E any, P PartitionKeyConstraint, S SortKeyConstraint](ctx context.Context, t *Table[E, P, S], stmt Statement) ([]T, error) {
+	input, err := t.createExecuteStatementInput(stmt, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	var items []T
+	for {
+		output, err := t.executeStatement(ctx, input)
+		if err != nil {
+			return items, err
+		}
+		var pageItems []T
+		err = attributevalue.UnmarshalListOfMaps(output.Items, &pageItems)
+		if err != nil {
+			return nil, fmt.Errorf("attributevalue.UnmarshalListOfMaps: %w", err)
+		}
+		items = append(items, pageItems...)
+		if output.NextToken == nil || *output.NextToken == "" {
+			return items, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// ExecuteStatementPageAs is the same as PartiQL.ExecutePage but decodes items into T
+func ExecuteStatementPageAs[T any, E any, P PartitionKeyConstraint, S SortKeyConstraint](ctx context.Context, t *Table[E, P, S], stmt Statement, startToken string, limit int) (items []T, nextToken string, err error) {
+	input, err := t.createExecuteStatementInput(stmt, startToken, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	output, err := t.executeStatement(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+	err = attributevalue.UnmarshalListOfMaps(output.Items, &items)
+	if err != nil {
+		return nil, "", fmt.Errorf("attributevalue.UnmarshalListOfMaps: %w", err)
+	}
+	if output.NextToken != nil && *output.NextToken != "" {
+		nextToken = encodeStatementToken(*output.NextToken)
+	}
+	return items, nextToken, nil
+}
+
+// BatchExecuteStatementsAs is the same as PartiQL.BatchExecute but decodes items into T
+func BatchExecuteStatementsAs[T any, E any, P PartitionKeyConstraint, S SortKeyConstraint](ctx context.Context, t *Table[E, P, S], statements ...Statement) ([]*BatchStatementResult[T], error) {
+	input := &dynamodb.BatchExecuteStatementInput{
+		Statements:             make([]types.BatchStatementRequest, len(statements)),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	for i, stmt := range statements {
+		params, err := marshalParams(stmt.Params)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		input.Statements[i] = types.BatchStatementRequest{
+			Statement:                           aws.String(stmt.SQL),
+			Parameters:                          params,
+			ConsistentRead:                      t.consistentRead,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+	}
+
+	c := startCall(opBatchExecuteStatement)
+	output, err := t.client.BatchExecuteStatement(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb.BatchExecuteStatement: %w", err)
+	}
+
+	results := make([]*BatchStatementResult[T], len(output.Responses))
+	for i, resp := range output.Responses {
+		r := new(BatchStatementResult[T])
+		results[i] = r
+		if resp.Error != nil {
+			r.Err = batchStatementError(resp.Error)
+			continue
+		}
+		if len(resp.Item) == 0 {
+			continue
+		}
+		if err = attributevalue.UnmarshalMap(resp.Item, &r.Item); err != nil {
+			r.Err = fmt.Errorf("attributevalue.UnmarshalMap: %w", err)
+			continue
+		}
+		r.Found = true
+	}
+	return results, nil
+}
+
+// ExecuteTransactionAs is the same as PartiQL.ExecuteTransaction but decodes items into T
+func ExecuteTransactionAs[T any, E any, P PartitionKeyConstraint, S SortKeyConstraint](ctx context.Context, t *Table[E, P, S], statements ...Statement) ([]T, error) {
+	input := &dynamodb.ExecuteTransactionInput{
+		TransactStatements:     make([]types.ParameterizedStatement, len(statements)),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	for i, stmt := range statements {
+		params, err := marshalParams(stmt.Params)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		input.TransactStatements[i] = types.ParameterizedStatement{
+			Statement:                           aws.String(stmt.SQL),
+			Parameters:                          params,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+	}
+
+	c := startCall(opExecuteTransaction)
+	output, err := t.client.ExecuteTransaction(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb.ExecuteTransaction: %w", wrapConditionFailed(err))
+	}
+
+	var items []T
+	for _, resp := range output.Responses {
+		if len(resp.Item) == 0 {
+			continue
+		}
+		var item T
+		if err = attributevalue.UnmarshalMap(resp.Item, &item); err != nil {
+			return nil, fmt.Errorf("attributevalue.UnmarshalMap: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (t *Table[E, P, S]) createExecuteStatementInput(stmt Statement, startToken string, limit int) (*dynamodb.ExecuteStatementInput, error) {
+	params, err := marshalParams(stmt.Params)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.ExecuteStatementInput{
+		Statement:                           aws.String(stmt.SQL),
+		Parameters:                          params,
+		ConsistentRead:                      t.consistentRead,
+		ReturnConsumedCapacity:              types.ReturnConsumedCapacityTotal,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(int32(limit))
+	}
+	if startToken != "" {
+		token, err := decodeStatementToken(startToken)
+		if err != nil {
+			return nil, err
+		}
+		input.NextToken = aws.String(token)
+	}
+	return input, nil
+}
+
+func (t *Table[E, P, S]) executeStatement(ctx context.Context, input *dynamodb.ExecuteStatementInput) (*dynamodb.ExecuteStatementOutput, error) {
+	c := startCall(opExecuteStatement)
+	output, err := t.client.ExecuteStatement(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb.ExecuteStatement: %w", wrapConditionFailed(err))
+	}
+	return output, nil
+}
+
+func marshalParams(params []any) ([]types.AttributeValue, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	avs := make([]types.AttributeValue, len(params))
+	for i, p := range params {
+		av, err := attributevalue.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("attributevalue.Marshal: param %d: %w", i, err)
+		}
+		avs[i] = av
+	}
+	return avs, nil
+}
+
+func batchStatementError(e *types.BatchStatementError) error {
+	err := fmt.Errorf("%s: %s", e.Code, aws.ToString(e.Message))
+	switch e.Code {
+	case types.BatchStatementErrorCodeEnumConditionalCheckFailed, types.BatchStatementErrorCodeEnumDuplicateItem:
+		return &ConditionFailedError{
+			Item: e.Item,
+			err:  err,
+		}
+	default:
+		return err
+	}
+}
+
+// statementTokenPrototype is the layout of tokens of statements, which wrap NextToken of dynamodb with the package's token codec
+var statementTokenPrototype = map[string]reflect.Type{
+	statementTokenName: reflect.TypeOf(&types.AttributeValueMemberS{}),
+}
+
+const statementTokenName = "NextToken"
+
+func encodeStatementToken(token string) string {
+	return encodeToken(map[string]types.AttributeValue{
+		statementTokenName: &types.AttributeValueMemberS{Value: token},
+	})
+}
+
+func decodeStatementToken(s string) (string, error) {
+	attrs, err := decodeToken(s, statementTokenPrototype)
+	if err != nil {
+		return "", goaws.ErrInvalidToken
+	}
+	token, ok := attrs[statementTokenName].(*types.AttributeValueMemberS)
+	if !ok || token.Value == "" {
+		return "", goaws.ErrInvalidToken
+	}
+	return token.Value, nil
+}
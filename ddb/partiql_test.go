@@ -0,0 +1,54 @@
+package ddb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+	goaws "go.olapie.com/aws"
+)
+
+type failingParam struct{}
+
+func (failingParam) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return nil, errors.New("failing param")
+}
+
+func TestMarshalParams(t *testing.T) {
+	params, err := marshalParams(nil)
+	require.NoError(t, err)
+	require.Nil(t, params)
+
+	params, err = marshalParams([]any{"u1", 10, true, []string{"a"}, nil})
+	require.NoError(t, err)
+	require.Equal(t, []types.AttributeValue{
+		&types.AttributeValueMemberS{Value: "u1"},
+		&types.AttributeValueMemberN{Value: "10"},
+		&types.AttributeValueMemberBOOL{Value: true},
+		&types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "a"}}},
+		&types.AttributeValueMemberNULL{Value: true},
+	}, params)
+
+	_, err = marshalParams([]any{"ok", failingParam{}})
+	require.ErrorContains(t, err, "param 1")
+}
+
+func TestStatementToken(t *testing.T) {
+	next := "AAAA+/opaque-token-from-dynamodb=="
+	token := encodeStatementToken(next)
+	decoded, err := decodeStatementToken(token)
+	require.NoError(t, err)
+	require.Equal(t, next, decoded)
+
+	for _, invalid := range []string{
+		"not base64!",
+		encodeToken(map[string]types.AttributeValue{}),
+		NewPrimaryKeyDefinition[string, NoKey]("id", "").EncodeValueToString(map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: "u1"},
+		}),
+	} {
+		_, err = decodeStatementToken(invalid)
+		require.ErrorIs(t, err, goaws.ErrInvalidToken, invalid)
+	}
+}
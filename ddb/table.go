@@ -33,6 +33,7 @@ type Table[E any, P PartitionKeyConstraint, S SortKeyConstraint] struct {
 	pkDefinition   *PrimaryKeyDefinition[P, S]
 	columns        []string
 	consistentRead *bool
+	observers      []Observer
 }
 
 func NewTable[E any, P PartitionKeyConstraint, S SortKeyConstraint](
@@ -59,6 +60,11 @@ func NewTable[E any, P PartitionKeyConstraint, S SortKeyConstraint](
 	for attr := range attrs {
 		t.columns = append(t.columns, attr)
 	}
+
+	// items of struct type must contain key attributes
+	if err = pk.validateItemType(reflect.TypeOf((*E)(nil)).Elem()); err != nil {
+		panic(fmt.Sprintf("ddb.NewTable %s: %v", tableName, err))
+	}
 	return t
 }
 
@@ -66,6 +72,24 @@ func (t *Table[E, P, S]) PrimaryKeyDefinition() *PrimaryKeyDefinition[P, S] {
 	return t.pkDefinition
 }
 
+// KeyOf returns primary key of item
+func (t *Table[E, P, S]) KeyOf(item E) (*PrimaryKey[P, S], error) {
+	return t.pkDefinition.KeyOf(item)
+}
+
+// KeysOf returns primary keys of items
+func (t *Table[E, P, S]) KeysOf(items ...E) ([]*PrimaryKey[P, S], error) {
+	keys := make([]*PrimaryKey[P, S], len(items))
+	for i, item := range items {
+		key, err := t.KeyOf(item)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
 func (t *Table[E, P, S]) Insert(ctx context.Context, item E) error {
 	return t.put(ctx, item, t.pkDefinition.attrNotExists)
 }
@@ -78,6 +102,23 @@ func (t *Table[E, P, S]) Put(ctx context.Context, item E) error {
 	return t.put(ctx, item, nil)
 }
 
+// PutAndReturnOld puts the item and returns the item it replaced.
+// found is false if there was no item with the same primary key.
+func (t *Table[E, P, S]) PutAndReturnOld(ctx context.Context, item E) (old E, found bool, err error) {
+	input, err := t.createPutInput(item, nil)
+	if err != nil {
+		return old, false, err
+	}
+	input.ReturnValues = types.ReturnValueAllOld
+	c := startCall(opPutItem)
+	output, err := t.client.PutItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return old, false, err
+	}
+	return unmarshalOld[E](output.Attributes)
+}
+
 func (t *Table[E, P, S]) BatchPut(ctx context.Context, items []E) error {
 	requests := make([]types.WriteRequest, len(items))
 	for i, item := range items {
@@ -90,10 +131,15 @@ func (t *Table[E, P, S]) BatchPut(ctx context.Context, items []E) error {
 		requests[i] = req
 	}
 
-	input := &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{
-		t.tableName: requests,
-	}}
-	_, err := t.client.BatchWriteItem(ctx, input)
+	input := &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{
+			t.tableName: requests,
+		},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	c := startCall(opBatchWriteItem)
+	output, err := t.client.BatchWriteItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	return err
 }
 
@@ -105,10 +151,13 @@ func (t *Table[E, P, S]) BatchGet(ctx context.Context, partitionKeys []P, sortKe
 		keysAndAttrs.Keys[i] = pk.AttributeValue()
 	}
 	input := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]types.KeysAndAttributes{t.tableName: keysAndAttrs},
+		RequestItems:           map[string]types.KeysAndAttributes{t.tableName: keysAndAttrs},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
 
-	output, err := t.client.BatchGetItem(ctx, input)
+	c := startCall(opBatchGetItem)
+	output, err := t.client.BatchGetItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	if err != nil {
 		return nil, fmt.Errorf("client.BatchGetItem: %w", err)
 	}
@@ -131,12 +180,15 @@ func (t *Table[E, P, S]) BatchGet(ctx context.Context, partitionKeys []P, sortKe
 
 func (t *Table[E, P, S]) Get(ctx context.Context, partitionKey P, sortKey S) (E, error) {
 	input := &dynamodb.GetItemInput{
-		Key:            t.pkDefinition.NewKey(partitionKey, sortKey).AttributeValue(),
-		TableName:      aws.String(t.tableName),
-		ConsistentRead: t.consistentRead,
+		Key:                    t.pkDefinition.NewKey(partitionKey, sortKey).AttributeValue(),
+		TableName:              aws.String(t.tableName),
+		ConsistentRead:         t.consistentRead,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
 	}
 	var item E
-	output, err := t.client.GetItem(ctx, input)
+	c := startCall(opGetItem)
+	output, err := t.client.GetItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	if err != nil {
 		return item, fmt.Errorf("dynamodb.GetItem: %w", err)
 	}
@@ -152,15 +204,104 @@ func (t *Table[E, P, S]) Get(ctx context.Context, partitionKey P, sortKey S) (E,
 	return item, nil
 }
 
+// Reload reads the latest version of item from table
+func (t *Table[E, P, S]) Reload(ctx context.Context, item E) (E, error) {
+	key, err := t.KeyOf(item)
+	if err != nil {
+		var zero E
+		return zero, err
+	}
+	return t.Get(ctx, key.PartitionKey, key.SortKey)
+}
+
+// Exists checks whether there is an item with the same primary key as item. Only key attributes are read.
+func (t *Table[E, P, S]) Exists(ctx context.Context, item E) (bool, error) {
+	key, err := t.KeyOf(item)
+	if err != nil {
+		return false, err
+	}
+	return t.ExistsKey(ctx, key.PartitionKey, key.SortKey)
+}
+
+// ExistsKey checks whether there is an item with the primary key. Only key attributes are read.
+func (t *Table[E, P, S]) ExistsKey(ctx context.Context, partitionKey P, sortKey S) (bool, error) {
+	proj := expression.NamesList(expression.Name(t.pkDefinition.partitionKeyName))
+	expr, err := expression.NewBuilder().WithProjection(proj).Build()
+	if err != nil {
+		return false, fmt.Errorf("expression.Build: %w", err)
+	}
+	input := &dynamodb.GetItemInput{
+		Key:                      t.pkDefinition.NewKey(partitionKey, sortKey).AttributeValue(),
+		TableName:                aws.String(t.tableName),
+		ConsistentRead:           t.consistentRead,
+		ProjectionExpression:     expr.Projection(),
+		ExpressionAttributeNames: expr.Names(),
+		ReturnConsumedCapacity:   types.ReturnConsumedCapacityTotal,
+	}
+	c := startCall(opGetItem)
+	output, err := t.client.GetItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return false, fmt.Errorf("dynamodb.GetItem: %w", err)
+	}
+	return output.Item != nil, nil
+}
+
 func (t *Table[E, P, S]) Delete(ctx context.Context, partitionKey P, sortKey S) error {
-	input := &dynamodb.DeleteItemInput{
-		Key:       t.pkDefinition.NewKey(partitionKey, sortKey).AttributeValue(),
-		TableName: aws.String(t.tableName),
+	input, err := t.createDeleteInput(partitionKey, sortKey, nil)
+	if err != nil {
+		return err
 	}
-	_, err := t.client.DeleteItem(ctx, input)
+	c := startCall(opDeleteItem)
+	output, err := t.client.DeleteItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	return err
 }
 
+// DeleteItem deletes the item with the same primary key as item
+func (t *Table[E, P, S]) DeleteItem(ctx context.Context, item E) error {
+	key, err := t.KeyOf(item)
+	if err != nil {
+		return err
+	}
+	return t.Delete(ctx, key.PartitionKey, key.SortKey)
+}
+
+// BatchDeleteItems deletes items with the same primary keys as items
+func (t *Table[E, P, S]) BatchDeleteItems(ctx context.Context, items ...E) error {
+	keys, err := t.KeysOf(items...)
+	if err != nil {
+		return err
+	}
+	return t.batchDelete(ctx, keys)
+}
+
+// DeleteAndReturnOld deletes the item and returns it as it appeared before deletion.
+// found is false if there was no such item.
+func (t *Table[E, P, S]) DeleteAndReturnOld(ctx context.Context, partitionKey P, sortKey S) (old E, found bool, err error) {
+	return t.deleteAndReturnOld(ctx, partitionKey, sortKey, nil)
+}
+
+// DeleteIf deletes the item only if cond is satisfied, otherwise *ConditionFailedError is returned
+func (t *Table[E, P, S]) DeleteIf(ctx context.Context, partitionKey P, sortKey S, cond expression.ConditionBuilder) error {
+	input, err := t.createDeleteInput(partitionKey, sortKey, &cond)
+	if err != nil {
+		return err
+	}
+	c := startCall(opDeleteItem)
+	output, err := t.client.DeleteItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return wrapConditionFailed(err)
+	}
+	return nil
+}
+
+// DeleteIfAndReturnOld is a combination of DeleteIf and DeleteAndReturnOld
+func (t *Table[E, P, S]) DeleteIfAndReturnOld(ctx context.Context, partitionKey P, sortKey S, cond expression.ConditionBuilder) (old E, found bool, err error) {
+	return t.deleteAndReturnOld(ctx, partitionKey, sortKey, &cond)
+}
+
 func (t *Table[E, P, S]) BatchDeleteInPartition(ctx context.Context, partitionKey P, sortKeys ...S) error {
 	pks := make([]*PrimaryKey[P, S], len(sortKeys))
 	for i, sk := range sortKeys {
@@ -204,6 +345,24 @@ func (t *Table[E, P, S]) PrepareTransactDelete(ctx context.Context, partitionKey
 	return deletes, nil
 }
 
+// PrepareTransactDeleteItems is the same as PrepareTransactDelete but takes keys from items
+func (t *Table[E, P, S]) PrepareTransactDeleteItems(ctx context.Context, items ...E) ([]types.TransactWriteItem, error) {
+	keys, err := t.KeysOf(items...)
+	if err != nil {
+		return nil, err
+	}
+	deletes := make([]types.TransactWriteItem, len(keys))
+	for i, key := range keys {
+		deletes[i] = types.TransactWriteItem{
+			Delete: &types.Delete{
+				Key:       key.AttributeValue(),
+				TableName: aws.String(t.tableName),
+			},
+		}
+	}
+	return deletes, nil
+}
+
 func (t *Table[E, P, S]) Query(ctx context.Context, partition P, sortKey *S, options ...func(input *dynamodb.QueryInput)) ([]E, error) {
 	input, err := t.createQueryInput(partition, sortKey, 1024)
 	if err != nil {
@@ -217,7 +376,9 @@ func (t *Table[E, P, S]) Query(ctx context.Context, partition P, sortKey *S, opt
 	var items []E
 	paginator := dynamodb.NewQueryPaginator(t.client, input)
 	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(ctx)
+		c := startCall(opQuery)
+		output, err := paginator.NextPage(ctx, c.option)
+		t.observe(ctx, c, input, output, err)
 		if err != nil {
 			return items, fmt.Errorf("paginator.NextPage: %w", err)
 		}
@@ -248,7 +409,9 @@ func (t *Table[E, P, S]) QueryPage(ctx context.Context, partition P, sortKey *S,
 		}
 	}
 
-	output, err := t.client.Query(ctx, input)
+	c := startCall(opQuery)
+	output, err := t.client.Query(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	if err != nil {
 		return nil, nextToken, fmt.Errorf("dynamodb.Query: %w", err)
 	}
@@ -313,6 +476,7 @@ func (t *Table[E, P, S]) createQueryInput(partition P, sortKey *S, limit int32)
 		IndexName:                 t.indexName,
 		Limit:                     aws.Int32(limit),
 		ConsistentRead:            t.consistentRead,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
 	}
 	return input, nil
 }
@@ -327,17 +491,33 @@ func (t *Table[E, P, S]) batchDelete(ctx context.Context, pks []*PrimaryKey[P, S
 		}
 	}
 
-	input := &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{
-		t.tableName: requests,
-	}}
-	_, err := t.client.BatchWriteItem(ctx, input)
+	input := &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{
+			t.tableName: requests,
+		},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	c := startCall(opBatchWriteItem)
+	output, err := t.client.BatchWriteItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
 	return err
 }
 
 func (t *Table[E, P, S]) put(ctx context.Context, item E, conditionExpression *string) error {
+	input, err := t.createPutInput(item, conditionExpression)
+	if err != nil {
+		return err
+	}
+	c := startCall(opPutItem)
+	output, err := t.client.PutItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	return err
+}
+
+func (t *Table[E, P, S]) createPutInput(item E, conditionExpression *string) (*dynamodb.PutItemInput, error) {
 	attrs, err := attributevalue.MarshalMap(item)
 	if err != nil {
-		return fmt.Errorf("attributevalue.MarshalMap: %w", err)
+		return nil, fmt.Errorf("attributevalue.MarshalMap: %w", err)
 	}
 	input := &dynamodb.PutItemInput{
 		Item:                   attrs,
@@ -345,8 +525,53 @@ func (t *Table[E, P, S]) put(ctx context.Context, item E, conditionExpression *s
 		TableName:              aws.String(t.tableName),
 		ConditionExpression:    conditionExpression,
 	}
-	_, err = t.client.PutItem(ctx, input)
-	return err
+	return input, nil
+}
+
+func (t *Table[E, P, S]) createDeleteInput(partitionKey P, sortKey S, cond *expression.ConditionBuilder) (*dynamodb.DeleteItemInput, error) {
+	input := &dynamodb.DeleteItemInput{
+		Key:                    t.pkDefinition.NewKey(partitionKey, sortKey).AttributeValue(),
+		TableName:              aws.String(t.tableName),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if cond != nil {
+		expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+		if err != nil {
+			return nil, fmt.Errorf("expression.Build: %w", err)
+		}
+		input.ConditionExpression = expr.Condition()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	return input, nil
+}
+
+func (t *Table[E, P, S]) deleteAndReturnOld(ctx context.Context, partitionKey P, sortKey S, cond *expression.ConditionBuilder) (old E, found bool, err error) {
+	input, err := t.createDeleteInput(partitionKey, sortKey, cond)
+	if err != nil {
+		return old, false, err
+	}
+	input.ReturnValues = types.ReturnValueAllOld
+	c := startCall(opDeleteItem)
+	output, err := t.client.DeleteItem(ctx, input, c.option)
+	t.observe(ctx, c, input, output, err)
+	if err != nil {
+		return old, false, wrapConditionFailed(err)
+	}
+	return unmarshalOld[E](output.Attributes)
+}
+
+// unmarshalOld decodes attributes returned with ReturnValues ALL_OLD, found is false if they are empty
+func unmarshalOld[E any](attrs map[string]types.AttributeValue) (old E, found bool, err error) {
+	if len(attrs) == 0 {
+		return old, false, nil
+	}
+	err = attributevalue.UnmarshalMap(attrs, &old)
+	if err != nil {
+		return old, false, fmt.Errorf("attributevalue.UnmarshalMap: %w", err)
+	}
+	return old, true, nil
 }
 
 func (t *Table[E, P, S]) prepareTransactPut(ctx context.Context, puts []E, conditionExpression *string) ([]types.TransactWriteItem, error) {
@@ -14,6 +14,8 @@ func (s ErrorString) Code() int {
 		return http.StatusBadRequest
 	case ErrItemNotFound, ErrKeyNotFound:
 		return http.StatusNotFound
+	case ErrConditionFailed, ErrObjectChanged:
+		return http.StatusPreconditionFailed
 	default:
 		return http.StatusInternalServerError
 	}
@@ -27,4 +29,10 @@ const (
 	ErrInvalidToken ErrorString = "invalid token"
 	ErrItemNotFound ErrorString = "item not found"
 	ErrKeyNotFound  ErrorString = "key not found"
+
+	ErrConditionFailed ErrorString = "condition failed"
+	ErrObjectChanged   ErrorString = "object changed"
+
+	// ErrNotEncrypted is returned when reading an object which is not encrypted by S3EncryptedBucket
+	ErrNotEncrypted ErrorString = "object not encrypted"
 )
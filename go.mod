@@ -15,6 +15,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sqs v1.32.3
 	github.com/aws/smithy-go v1.20.2
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.8.1
 	go.olapie.com/router v1.1.2
 	go.olapie.com/x/xbase62 v0.1.1
@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"context"
+	"sync"
+)
+
+// parallel calls fn for index 0 ~ n-1 with at most concurrency goroutines.
+// It stops calling fn after the first error, which is returned.
+func parallel(ctx context.Context, concurrency, n int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	indexes := make(chan int)
+	for i := 0; i < min(concurrency, n); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
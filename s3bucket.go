@@ -33,6 +33,9 @@ type S3Bucket struct {
 
 	ACL          types.ObjectCannedACL
 	CacheControl string
+
+	// Compression is the encoding used to compress content written by Put and PutStream, e.g. "gzip"
+	Compression string
 }
 
 func NewS3Bucket(bucket string, c *s3.Client) *S3Bucket {
@@ -54,6 +57,9 @@ func NewS3BucketFromConfig(bucket string, cfg aws.Config, options ...func(*s3.Op
 }
 
 func (s *S3Bucket) Put(ctx context.Context, key string, content []byte, metadata map[string]string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	if s.Compression != "" {
+		return s.putCompressed(ctx, key, bytes.NewReader(content), int64(len(content)), http.DetectContentType(content), metadata, s.Compression, optFns...)
+	}
 	input := &s3.PutObjectInput{
 		Bucket:       aws.String(s.bucket),
 		Key:          aws.String(key),
@@ -106,11 +112,19 @@ func (s *S3Bucket) Get(ctx context.Context, key string, optFns ...func(input *s3
 		return nil, fmt.Errorf("s3.GetObject: %w", err)
 	}
 
-	content, err := io.ReadAll(output.Body)
+	body, _, err := decompressBody(output.Body, &S3ObjectInfo{
+		Key:             key,
+		Metadata:        output.Metadata,
+		ContentEncoding: xconv.Dereference(output.ContentEncoding),
+	})
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(body)
 	if err != nil {
 		return nil, fmt.Errorf("io.ReadAll: %w", err)
 	}
-	output.Body.Close()
+	body.Close()
 
 	return content, nil
 }
@@ -164,6 +178,7 @@ func (s *S3Bucket) AbortMultipartUpload(ctx context.Context, key, uploadID strin
 	return err
 }
 
+// ListMultipartUploads returns all in-progress multipart uploads, following markers until the listing is complete
 func (s *S3Bucket) ListMultipartUploads(ctx context.Context, optFns ...func(*s3.ListMultipartUploadsInput)) ([]types.MultipartUpload, error) {
 	input := &s3.ListMultipartUploadsInput{
 		Bucket: aws.String(s.bucket),
@@ -171,11 +186,19 @@ func (s *S3Bucket) ListMultipartUploads(ctx context.Context, optFns ...func(*s3.
 	for _, fn := range optFns {
 		fn(input)
 	}
-	output, err := s.client.ListMultipartUploads(ctx, input)
-	if err != nil {
-		return nil, err
+	var uploads []types.MultipartUpload
+	for {
+		output, err := s.client.ListMultipartUploads(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, output.Uploads...)
+		if !xconv.Dereference(output.IsTruncated) {
+			return uploads, nil
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.UploadIdMarker = output.NextUploadIdMarker
 	}
-	return output.Uploads, nil
 }
 
 // ListParts only works if upload is not completed or aborted
@@ -0,0 +1,355 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.olapie.com/x/xconv"
+)
+
+const (
+	// maxCopyObjectSize is the maximum size of an object copied with a single request
+	maxCopyObjectSize = 5 << 30
+
+	defaultCopyPartSize    = 512 << 20
+	defaultCopyConcurrency = 4
+)
+
+type S3CopyOptions struct {
+	// Metadata replaces the metadata of source object if it's not nil
+	Metadata map[string]string
+
+	// ContentType replaces the content type of source object if it's not empty
+	ContentType string
+
+	// PartSize is the size of each part when copying an object larger than 5GB
+	PartSize int64
+
+	// Concurrency is the number of objects or parts copied in parallel
+	Concurrency int
+
+	// Progress is called after an object is copied
+	Progress func(p *S3CopyProgress)
+
+	// NoOverwrite fails the copy with ErrConditionFailed if the destination key exists.
+	// As S3 doesn't tell which precondition failed, it's also returned if the source is changed while copying.
+	NoOverwrite bool
+}
+
+type S3CopyProgress struct {
+	Key          string
+	Objects      int
+	TotalObjects int
+	Bytes        int64
+	TotalBytes   int64
+}
+
+// Copy copies the object of srcKey to dstKey in dst. If dst is nil, the object is copied in the same bucket.
+// Objects larger than 5GB are copied with multipart upload. It returns the ETag of the new object.
+func (s *S3Bucket) Copy(ctx context.Context, srcKey string, dst *S3Bucket, dstKey string, optFns ...func(options *S3CopyOptions)) (string, error) {
+	options := newS3CopyOptions(optFns...)
+	info, err := s.Stat(ctx, srcKey)
+	if err != nil {
+		return "", err
+	}
+	etag, err := s.copyObject(ctx, info, dst, dstKey, options)
+	if err != nil {
+		return "", err
+	}
+	if options.Progress != nil {
+		options.Progress(&S3CopyProgress{
+			Key:          srcKey,
+			Objects:      1,
+			TotalObjects: 1,
+			Bytes:        info.Size,
+			TotalBytes:   info.Size,
+		})
+	}
+	return etag, nil
+}
+
+// Move copies the object then deletes the source.
+// The source is kept if it's the same object as the destination, e.g. when only metadata is replaced.
+func (s *S3Bucket) Move(ctx context.Context, srcKey string, dst *S3Bucket, dstKey string, optFns ...func(options *S3CopyOptions)) (string, error) {
+	etag, err := s.Copy(ctx, srcKey, dst, dstKey, optFns...)
+	if err != nil {
+		return "", err
+	}
+	if s.isSameBucket(dst) && srcKey == dstKey {
+		return etag, nil
+	}
+	if err = s.Delete(ctx, srcKey); err != nil {
+		return etag, fmt.Errorf("delete: %w", err)
+	}
+	return etag, nil
+}
+
+// CopyPrefix copies all objects under srcPrefix to dstPrefix concurrently.
+// It returns the number of copied objects.
+func (s *S3Bucket) CopyPrefix(ctx context.Context, srcPrefix string, dst *S3Bucket, dstPrefix string, optFns ...func(options *S3CopyOptions)) (int, error) {
+	copied, err := s.copyPrefix(ctx, srcPrefix, dst, dstPrefix, newS3CopyOptions(optFns...))
+	return len(copied), err
+}
+
+// MovePrefix copies all objects under srcPrefix to dstPrefix, then deletes copied objects.
+// It returns the number of moved objects. Prefixes overlapping in the same bucket are rejected,
+// as copied objects could overwrite sources or be deleted as sources.
+func (s *S3Bucket) MovePrefix(ctx context.Context, srcPrefix string, dst *S3Bucket, dstPrefix string, optFns ...func(options *S3CopyOptions)) (int, error) {
+	if s.isSameBucket(dst) && (strings.HasPrefix(srcPrefix, dstPrefix) || strings.HasPrefix(dstPrefix, srcPrefix)) {
+		return 0, fmt.Errorf("move %q to overlapping prefix %q", srcPrefix, dstPrefix)
+	}
+	copied, err := s.copyPrefix(ctx, srcPrefix, dst, dstPrefix, newS3CopyOptions(optFns...))
+	for i := 0; i < len(copied); i += maxListKeys {
+		if delErr := s.BatchDelete(ctx, copied[i:min(i+maxListKeys, len(copied))]); delErr != nil {
+			return i, fmt.Errorf("batchDelete: %w", delErr)
+		}
+	}
+	return len(copied), err
+}
+
+// isSameBucket reports whether dst is the same bucket as s, nil means the same bucket
+func (s *S3Bucket) isSameBucket(dst *S3Bucket) bool {
+	return dst == nil || dst.bucket == s.bucket
+}
+
+func newS3CopyOptions(optFns ...func(options *S3CopyOptions)) *S3CopyOptions {
+	options := &S3CopyOptions{
+		PartSize:    defaultCopyPartSize,
+		Concurrency: defaultCopyConcurrency,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.PartSize < minPartSize {
+		options.PartSize = minPartSize
+	}
+	if options.PartSize > maxCopyObjectSize {
+		options.PartSize = maxCopyObjectSize
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	return options
+}
+
+// copyPrefix returns keys of copied objects, which is valid even if error occurs
+func (s *S3Bucket) copyPrefix(ctx context.Context, srcPrefix string, dst *S3Bucket, dstPrefix string, options *S3CopyOptions) ([]string, error) {
+	objects, _, err := s.List(ctx, srcPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var totalBytes int64
+	for _, obj := range objects {
+		totalBytes += obj.Size
+	}
+
+	var (
+		mu          sync.Mutex
+		copied      []string
+		copiedBytes int64
+	)
+	err = parallel(ctx, options.Concurrency, len(objects), func(ctx context.Context, i int) error {
+		obj := objects[i]
+		dstKey := dstPrefix + strings.TrimPrefix(obj.Key, srcPrefix)
+		// listing doesn't return content type and metadata
+		info, err := s.Stat(ctx, obj.Key)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", obj.Key, err)
+		}
+		if _, err = s.copyObject(ctx, info, dst, dstKey, options); err != nil {
+			return fmt.Errorf("copy %s: %w", obj.Key, err)
+		}
+
+		mu.Lock()
+		copied = append(copied, obj.Key)
+		copiedBytes += obj.Size
+		progress := &S3CopyProgress{
+			Key:          obj.Key,
+			Objects:      len(copied),
+			TotalObjects: len(objects),
+			Bytes:        copiedBytes,
+			TotalBytes:   totalBytes,
+		}
+		mu.Unlock()
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+		return nil
+	})
+	return copied, err
+}
+
+func (s *S3Bucket) copyObject(ctx context.Context, src *S3ObjectInfo, dst *S3Bucket, dstKey string, options *S3CopyOptions) (string, error) {
+	if dst == nil {
+		dst = s
+	}
+
+	if src.Size > maxCopyObjectSize {
+		return s.multipartCopy(ctx, src, dst, dstKey, options)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(dst.bucket),
+		Key:               aws.String(dstKey),
+		CopySource:        aws.String(copySource(s.bucket, src.Key)),
+		CopySourceIfMatch: aws.String(src.ETag),
+		ACL:               dst.ACL,
+	}
+	if options.Metadata != nil || options.ContentType != "" {
+		head, metadata, err := s.replacedHeaders(ctx, src, options)
+		if err != nil {
+			return "", err
+		}
+		input.MetadataDirective = types.MetadataDirectiveReplace
+		input.CacheControl = aws.String(dst.CacheControl)
+		input.ContentType = head.ContentType
+		input.ContentDisposition = head.ContentDisposition
+		input.ContentEncoding = head.ContentEncoding
+		input.ContentLanguage = head.ContentLanguage
+		input.Metadata = metadata
+		if options.ContentType != "" {
+			input.ContentType = aws.String(options.ContentType)
+		}
+	}
+	output, err := dst.client.CopyObject(ctx, input, options.writeOptions()...)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			if options.NoOverwrite {
+				return "", ErrConditionFailed
+			}
+			return "", ErrObjectChanged
+		}
+		return "", fmt.Errorf("s3.CopyObject: %w", err)
+	}
+	if output.CopyObjectResult == nil {
+		return "", nil
+	}
+	return xconv.Dereference(output.CopyObjectResult.ETag), nil
+}
+
+func (s *S3Bucket) multipartCopy(ctx context.Context, src *S3ObjectInfo, dst *S3Bucket, dstKey string, options *S3CopyOptions) (string, error) {
+	partSize := fitPartSize(src.Size, options.PartSize)
+	head, metadata, err := s.replacedHeaders(ctx, src, options)
+	if err != nil {
+		return "", err
+	}
+	uploadID, err := dst.CreateMultipartUpload(ctx, dstKey, func(input *s3.CreateMultipartUploadInput) {
+		input.ContentType = head.ContentType
+		input.ContentDisposition = head.ContentDisposition
+		input.ContentEncoding = head.ContentEncoding
+		input.ContentLanguage = head.ContentLanguage
+		input.Metadata = metadata
+		if options.ContentType != "" {
+			input.ContentType = aws.String(options.ContentType)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("createMultipartUpload: %w", err)
+	}
+
+	n := int((src.Size + partSize - 1) / partSize)
+	parts := make([]types.CompletedPart, n)
+	err = parallel(ctx, options.Concurrency, n, func(ctx context.Context, i int) error {
+		start := int64(i) * partSize
+		end := min(start+partSize, src.Size) - 1
+		output, err := dst.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(dst.bucket),
+			Key:               aws.String(dstKey),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(int32(i + 1)),
+			CopySource:        aws.String(copySource(s.bucket, src.Key)),
+			CopySourceIfMatch: aws.String(src.ETag),
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		})
+		if err != nil {
+			if isPreconditionFailed(err) {
+				return ErrObjectChanged
+			}
+			return fmt.Errorf("s3.UploadPartCopy: part %d: %w", i+1, err)
+		}
+		parts[i] = types.CompletedPart{
+			PartNumber: aws.Int32(int32(i + 1)),
+		}
+		if output.CopyPartResult != nil {
+			parts[i].ETag = output.CopyPartResult.ETag
+		}
+		return nil
+	})
+	if err != nil {
+		_ = dst.AbortMultipartUpload(context.WithoutCancel(ctx), dstKey, uploadID)
+		return "", err
+	}
+
+	output, err := dst.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(dst.bucket),
+		Key:      aws.String(dstKey),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	}, options.writeOptions()...)
+	if err != nil {
+		_ = dst.AbortMultipartUpload(context.WithoutCancel(ctx), dstKey, uploadID)
+		if options.NoOverwrite && isPreconditionFailed(err) {
+			return "", ErrConditionFailed
+		}
+		return "", fmt.Errorf("s3.CompleteMultipartUpload: %w", err)
+	}
+	return xconv.Dereference(output.ETag), nil
+}
+
+// writeOptions returns options of the request which creates the destination object
+func (o *S3CopyOptions) writeOptions() []func(*s3.Options) {
+	if !o.NoOverwrite {
+		return nil
+	}
+	return []func(*s3.Options){ifNoneMatch}
+}
+
+// ifNoneMatch makes a write fail with 412 if the key exists.
+// Conditional headers of writes are not modeled by the sdk version in use.
+func ifNoneMatch(o *s3.Options) {
+	o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue("If-None-Match", "*"))
+}
+
+// copySource returns url-encoded "bucket/key"
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
+// replacedHeaders returns headers of src which are carried over when its metadata is replaced, and the new metadata.
+// Metadata of compression is kept, otherwise compressed content can't be decompressed.
+func (s *S3Bucket) replacedHeaders(ctx context.Context, src *S3ObjectInfo, options *S3CopyOptions) (*s3.HeadObjectOutput, map[string]string, error) {
+	head, err := s.GetHeadObject(ctx, src.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("head %s: %w", src.Key, err)
+	}
+	if xconv.Dereference(head.ETag) != src.ETag {
+		return nil, nil, ErrObjectChanged
+	}
+	if options.Metadata == nil {
+		return head, head.Metadata, nil
+	}
+	metadata := make(map[string]string, len(options.Metadata)+2)
+	for k, v := range options.Metadata {
+		metadata[k] = v
+	}
+	for _, k := range []string{metaCompression, metaUncompressedSize} {
+		if _, ok := metadata[k]; !ok && head.Metadata[k] != "" {
+			metadata[k] = head.Metadata[k]
+		}
+	}
+	return head, metadata, nil
+}
@@ -0,0 +1,191 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.olapie.com/x/xconv"
+)
+
+const maxListKeys = 1000
+
+// S3ListPage is a page of objects under a prefix
+type S3ListPage struct {
+	Objects []*S3ObjectInfo
+
+	// CommonPrefixes are "folders" directly under the prefix if delimiter is specified
+	CommonPrefixes []string
+
+	// NextToken is empty if there are no more objects
+	NextToken string
+}
+
+// listToken binds a continuation token to the prefix and delimiter it's created for
+type listToken struct {
+	Prefix            string `json:"p,omitempty"`
+	Delimiter         string `json:"d,omitempty"`
+	ContinuationToken string `json:"t"`
+}
+
+// ListPage lists a page of objects whose keys start with prefix.
+// If delimiter is not empty, keys containing delimiter after prefix are grouped into CommonPrefixes.
+// startToken is the NextToken of the previous page, it's safe to be returned to clients.
+func (s *S3Bucket) ListPage(ctx context.Context, prefix, delimiter, startToken string, limit int, optFns ...func(input *s3.ListObjectsV2Input)) (*S3ListPage, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	if limit > 0 && limit < maxListKeys {
+		input.MaxKeys = aws.Int32(int32(limit))
+	}
+	if startToken != "" {
+		token, err := decodeListToken(startToken, prefix, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		input.ContinuationToken = aws.String(token)
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+
+	output, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("s3.ListObjectsV2: %w", err)
+	}
+
+	page := &S3ListPage{
+		Objects:        make([]*S3ObjectInfo, len(output.Contents)),
+		CommonPrefixes: make([]string, len(output.CommonPrefixes)),
+	}
+	for i, obj := range output.Contents {
+		page.Objects[i] = newS3ObjectInfo(obj)
+	}
+	for i, p := range output.CommonPrefixes {
+		page.CommonPrefixes[i] = xconv.Dereference(p.Prefix)
+	}
+	if xconv.Dereference(output.IsTruncated) && output.NextContinuationToken != nil {
+		page.NextToken = encodeListToken(*output.NextContinuationToken, prefix, delimiter)
+	}
+	return page, nil
+}
+
+// List lists all objects and common prefixes under prefix
+func (s *S3Bucket) List(ctx context.Context, prefix, delimiter string) (objects []*S3ObjectInfo, commonPrefixes []string, err error) {
+	token := ""
+	for {
+		page, err := s.ListPage(ctx, prefix, delimiter, token, maxListKeys)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, page.Objects...)
+		commonPrefixes = append(commonPrefixes, page.CommonPrefixes...)
+		if page.NextToken == "" {
+			return objects, commonPrefixes, nil
+		}
+		token = page.NextToken
+	}
+}
+
+// Iterate returns an iterator of all objects whose keys start with prefix
+func (s *S3Bucket) Iterate(ctx context.Context, prefix string) *S3ObjectIterator {
+	return &S3ObjectIterator{
+		ctx: ctx,
+		paginator: s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(s.bucket),
+			Prefix: aws.String(prefix),
+		}),
+	}
+}
+
+// S3ObjectIterator iterates objects page by page
+//
+//	it := bucket.Iterate(ctx, "photos/")
+//	for it.Next() {
+//		obj := it.Object()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type S3ObjectIterator struct {
+	ctx       context.Context
+	paginator *s3.ListObjectsV2Paginator
+	objects   []types.Object
+	current   *S3ObjectInfo
+	err       error
+}
+
+// Next advances to the next object. It returns false when all objects are iterated or an error occurs.
+func (it *S3ObjectIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for len(it.objects) == 0 {
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		if !it.paginator.HasMorePages() {
+			return false
+		}
+		output, err := it.paginator.NextPage(it.ctx)
+		if err != nil {
+			it.err = fmt.Errorf("s3.ListObjectsV2: %w", err)
+			return false
+		}
+		it.objects = output.Contents
+	}
+	it.current = newS3ObjectInfo(it.objects[0])
+	it.objects = it.objects[1:]
+	return true
+}
+
+func (it *S3ObjectIterator) Object() *S3ObjectInfo {
+	return it.current
+}
+
+func (it *S3ObjectIterator) Err() error {
+	return it.err
+}
+
+func newS3ObjectInfo(obj types.Object) *S3ObjectInfo {
+	return &S3ObjectInfo{
+		Key:          xconv.Dereference(obj.Key),
+		Size:         xconv.Dereference(obj.Size),
+		ETag:         xconv.Dereference(obj.ETag),
+		LastModified: xconv.Dereference(obj.LastModified),
+	}
+}
+
+func encodeListToken(continuationToken, prefix, delimiter string) string {
+	data, _ := json.Marshal(&listToken{
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		ContinuationToken: continuationToken,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListToken(s, prefix, delimiter string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var token listToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return "", ErrInvalidToken
+	}
+	if token.Prefix != prefix || token.Delimiter != delimiter || token.ContinuationToken == "" {
+		return "", ErrInvalidToken
+	}
+	return token.ContinuationToken, nil
+}
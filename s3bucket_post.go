@@ -0,0 +1,212 @@
+package aws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	postPolicyAlgorithm = "AWS4-HMAC-SHA256"
+	postPolicyDate      = "20060102T150405Z"
+)
+
+// S3PostPolicy restricts what a browser can upload with a presigned POST form
+type S3PostPolicy struct {
+	// Key is the exact key of the object. Either Key or KeyPrefix must be specified.
+	Key string
+
+	// KeyPrefix allows any key starting with it. Browser sets the key field, which defaults to KeyPrefix+"${filename}"
+	KeyPrefix string
+
+	// ContentLengthMin and ContentLengthMax limit the size of uploaded content if ContentLengthMax is positive
+	ContentLengthMin int64
+	ContentLengthMax int64
+
+	// ContentType is the exact content type
+	ContentType string
+
+	// ContentTypePrefix allows any content type starting with it, e.g. "image/"
+	ContentTypePrefix string
+
+	// Metadata must be submitted as it is
+	Metadata map[string]string
+
+	// SuccessActionRedirect is the url which browser is redirected to after uploading
+	SuccessActionRedirect string
+
+	// SuccessActionStatus is the status code returned after uploading if there is no redirect.
+	// It's 204 by default, and can be 200, 201 or 204.
+	SuccessActionStatus int
+
+	// ACL defaults to S3Bucket.ACL
+	ACL types.ObjectCannedACL
+
+	// CacheControl defaults to S3Bucket.CacheControl
+	CacheControl string
+}
+
+// S3PresignedPost contains the url and form fields of a direct-to-S3 HTML upload form.
+// The file field must be the last field of the form.
+type S3PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PreSignPost creates a presigned POST form with bucket's client credentials and region.
+// Nothing is sent to S3.
+func (s *S3Bucket) PreSignPost(ctx context.Context, policy *S3PostPolicy, ttl time.Duration) (*S3PresignedPost, error) {
+	options := s.client.Options()
+	if options.Credentials == nil {
+		return nil, errors.New("no credentials")
+	}
+	creds, err := options.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve credentials: %w", err)
+	}
+
+	p := *policy
+	if p.ACL == "" {
+		p.ACL = s.ACL
+	}
+	if p.CacheControl == "" {
+		p.CacheControl = s.CacheControl
+	}
+
+	now := time.Now()
+	post, err := PreSignS3Post(creds, options.Region, s.bucket, &p, now, now.Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case options.BaseEndpoint != nil:
+		post.URL = strings.TrimSuffix(*options.BaseEndpoint, "/") + "/" + s.bucket
+	case options.UsePathStyle || strings.Contains(s.bucket, "."):
+		post.URL = fmt.Sprintf("https://s3.%s.amazonaws.com/%s", options.Region, s.bucket)
+	}
+	return post, nil
+}
+
+// PreSignS3Post creates a presigned POST form with the given credentials, which works offline.
+// signTime is the time of signing, which is set as x-amz-date and must be before expires.
+func PreSignS3Post(creds aws.Credentials, region, bucket string, policy *S3PostPolicy, signTime, expires time.Time) (*S3PresignedPost, error) {
+	if policy.Key == "" && policy.KeyPrefix == "" {
+		return nil, errors.New("missing key or key prefix")
+	}
+	if policy.ContentLengthMax > 0 && policy.ContentLengthMin > policy.ContentLengthMax {
+		return nil, errors.New("invalid content length range")
+	}
+	switch policy.SuccessActionStatus {
+	case 0, http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	default:
+		return nil, fmt.Errorf("invalid success action status %d", policy.SuccessActionStatus)
+	}
+	if !signTime.Before(expires) {
+		return nil, errors.New("sign time is not before expires")
+	}
+
+	date := signTime.UTC().Format(postPolicyDate)
+	scope := strings.Join([]string{date[:8], region, "s3", "aws4_request"}, "/")
+	credential := creds.AccessKeyID + "/" + scope
+
+	fields := map[string]string{
+		"x-amz-algorithm":  postPolicyAlgorithm,
+		"x-amz-credential": credential,
+		"x-amz-date":       date,
+	}
+	conditions := []any{
+		map[string]string{"bucket": bucket},
+	}
+	addField := func(name, value string) {
+		fields[name] = value
+		conditions = append(conditions, map[string]string{name: value})
+	}
+
+	if policy.Key != "" {
+		addField("key", policy.Key)
+	} else {
+		fields["key"] = policy.KeyPrefix + "${filename}"
+		conditions = append(conditions, []any{"starts-with", "$key", policy.KeyPrefix})
+	}
+
+	if policy.ACL != "" {
+		addField("acl", string(policy.ACL))
+	}
+
+	if policy.CacheControl != "" {
+		addField("Cache-Control", policy.CacheControl)
+	}
+
+	if policy.ContentType != "" {
+		addField("Content-Type", policy.ContentType)
+	} else if policy.ContentTypePrefix != "" {
+		conditions = append(conditions, []any{"starts-with", "$Content-Type", policy.ContentTypePrefix})
+	}
+
+	if policy.ContentLengthMax > 0 {
+		conditions = append(conditions, []any{"content-length-range", policy.ContentLengthMin, policy.ContentLengthMax})
+	}
+
+	for k, v := range policy.Metadata {
+		addField("x-amz-meta-"+strings.ToLower(k), v)
+	}
+
+	if policy.SuccessActionRedirect != "" {
+		addField("success_action_redirect", policy.SuccessActionRedirect)
+	} else if policy.SuccessActionStatus != 0 {
+		addField("success_action_status", strconv.Itoa(policy.SuccessActionStatus))
+	}
+
+	if creds.SessionToken != "" {
+		addField("x-amz-security-token", creds.SessionToken)
+	}
+
+	for _, name := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date"} {
+		conditions = append(conditions, map[string]string{name: fields[name]})
+	}
+
+	doc, err := json.Marshal(map[string]any{
+		"expiration": expires.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	encodedPolicy := base64.StdEncoding.EncodeToString(doc)
+	fields["policy"] = encodedPolicy
+
+	key := signingKey(creds.SecretAccessKey, date[:8], region, "s3")
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, []byte(encodedPolicy)))
+
+	return &S3PresignedPost{
+		URL:    fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", bucket, region),
+		Fields: fields,
+	}, nil
+}
+
+// signingKey derives the key of signature version 4
+func signingKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,172 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.olapie.com/x/xconv"
+)
+
+const (
+	defaultContentType = "application/octet-stream"
+
+	// minPartSize is the minimum size of a part except the last one in a multipart upload
+	minPartSize = 5 << 20
+)
+
+// S3ObjectInfo is the metadata of an object
+type S3ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	Metadata     map[string]string
+	LastModified time.Time
+
+	// Checksum is only set by methods verifying checksums
+	Checksum *S3Checksum
+
+	// ContentEncoding is set by methods reading headers, e.g. "gzip"
+	ContentEncoding string
+}
+
+// PutStream uploads content read from r without loading it into memory.
+// size is the length of content. If it's negative, the content is uploaded in parts of 5MB.
+// If contentType is empty, it's detected by the extension of key.
+// If S3Bucket.Compression is set, content is compressed, and a non-negative size is stored as the original size,
+// which the content must match.
+func (s *S3Bucket) PutStream(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	if s.Compression != "" {
+		return s.putCompressed(ctx, key, r, size, contentType, metadata, s.Compression, optFns...)
+	}
+	return s.putStream(ctx, key, r, size, contentType, metadata, optFns...)
+}
+
+func (s *S3Bucket) putStream(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	if size < 0 {
+		return s.putUnknownSizeStream(ctx, key, r, contentTypeOf(key, contentType), metadata, optFns...)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+		ACL:           s.ACL,
+		CacheControl:  aws.String(s.CacheControl),
+		ContentType:   aws.String(contentTypeOf(key, contentType)),
+		Metadata:      metadata,
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+	output, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("s3.PutObject: %w", err)
+	}
+	return xconv.Dereference(output.ETag), nil
+}
+
+// GetStream returns the content stream and metadata of the object. The caller must close the stream.
+// Content compressed by PutCompressed is decompressed, use GetRawStream to read compressed bytes.
+func (s *S3Bucket) GetStream(ctx context.Context, key string, optFns ...func(input *s3.GetObjectInput)) (io.ReadCloser, *S3ObjectInfo, error) {
+	body, info, err := s.GetRawStream(ctx, key, optFns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decompressBody(body, info)
+}
+
+// GetRawStream is the same as GetStream except that compressed content is returned as it is
+func (s *S3Bucket) GetRawStream(ctx context.Context, key string, optFns ...func(input *s3.GetObjectInput)) (io.ReadCloser, *S3ObjectInfo, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, nil, ErrKeyNotFound
+		}
+		return nil, nil, fmt.Errorf("s3.GetObject: %w", err)
+	}
+
+	info := &S3ObjectInfo{
+		Key:          key,
+		Size:         xconv.Dereference(output.ContentLength),
+		ETag:         xconv.Dereference(output.ETag),
+		ContentType:  xconv.Dereference(output.ContentType),
+		Metadata:     output.Metadata,
+		LastModified: xconv.Dereference(output.LastModified),
+
+		ContentEncoding: xconv.Dereference(output.ContentEncoding),
+	}
+	return output.Body, info, nil
+}
+
+// Stat returns the metadata of the object
+func (s *S3Bucket) Stat(ctx context.Context, key string, optFns ...func(*s3.HeadObjectInput)) (*S3ObjectInfo, error) {
+	output, err := s.GetHeadObject(ctx, key, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return &S3ObjectInfo{
+		Key:          key,
+		Size:         xconv.Dereference(output.ContentLength),
+		ETag:         xconv.Dereference(output.ETag),
+		ContentType:  xconv.Dereference(output.ContentType),
+		Metadata:     output.Metadata,
+		LastModified: xconv.Dereference(output.LastModified),
+
+		ContentEncoding: xconv.Dereference(output.ContentEncoding),
+	}, nil
+}
+
+// putUnknownSizeStream uploads content in one request if it's smaller than a part, otherwise uploads it part by part
+func (s *S3Bucket) putUnknownSizeStream(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	putInput := &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ACL:          s.ACL,
+		CacheControl: aws.String(s.CacheControl),
+		ContentType:  aws.String(contentType),
+		Metadata:     metadata,
+	}
+	for _, fn := range optFns {
+		fn(putInput)
+	}
+	uploader := NewS3Uploader(s, func(options *S3UploaderOptions) {
+		options.PartSize = minPartSize
+		options.Concurrency = 1
+	})
+	result, err := uploader.Upload(ctx, key, r, func(input *s3.CreateMultipartUploadInput) {
+		*input = *multipartInputFromPutObject(putInput)
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.ETag, nil
+}
+
+func contentTypeOf(key, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return defaultContentType
+}
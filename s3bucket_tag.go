@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.olapie.com/x/xconv"
+)
+
+const defaultTagConcurrency = 8
+
+// GetTags returns tags of the object
+func (s *S3Bucket) GetTags(ctx context.Context, key string, optFns ...func(input *s3.GetObjectTaggingInput)) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+	output, err := s.client.GetObjectTagging(ctx, input)
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("s3.GetObjectTagging: %w", err)
+	}
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[xconv.Dereference(tag.Key)] = xconv.Dereference(tag.Value)
+	}
+	return tags, nil
+}
+
+// PutTags replaces all tags of the object
+func (s *S3Bucket) PutTags(ctx context.Context, key string, tags map[string]string, optFns ...func(input *s3.PutObjectTaggingInput)) error {
+	input := &s3.PutObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Tagging: &types.Tagging{
+			TagSet: make([]types.Tag, 0, len(tags)),
+		},
+	}
+	for k, v := range tags {
+		input.Tagging.TagSet = append(input.Tagging.TagSet, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+	if _, err := s.client.PutObjectTagging(ctx, input); err != nil {
+		if isNoSuchKey(err) {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("s3.PutObjectTagging: %w", err)
+	}
+	return nil
+}
+
+// AddTags merges tags into existing tags of the object
+func (s *S3Bucket) AddTags(ctx context.Context, key string, tags map[string]string) error {
+	existing, err := s.GetTags(ctx, key)
+	if err != nil {
+		return err
+	}
+	for k, v := range tags {
+		existing[k] = v
+	}
+	return s.PutTags(ctx, key, existing)
+}
+
+// DeleteTags removes all tags of the object
+func (s *S3Bucket) DeleteTags(ctx context.Context, key string, optFns ...func(input *s3.DeleteObjectTaggingInput)) error {
+	input := &s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+	if _, err := s.client.DeleteObjectTagging(ctx, input); err != nil {
+		if isNoSuchKey(err) {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("s3.DeleteObjectTagging: %w", err)
+	}
+	return nil
+}
+
+// S3MetadataUpdate is applied to an object by UpdateMetadata. Empty fields are kept unchanged.
+type S3MetadataUpdate struct {
+	// Metadata replaces all user metadata if it's not nil
+	Metadata map[string]string
+
+	ContentType  string
+	CacheControl string
+}
+
+// UpdateMetadata changes metadata, content type or cache control of the object by copying it onto itself.
+// Tags and other headers are kept. It fails with ErrObjectChanged if the object is overwritten concurrently.
+// Objects larger than 5GB can't be updated this way.
+func (s *S3Bucket) UpdateMetadata(ctx context.Context, key string, update *S3MetadataUpdate) (string, error) {
+	head, err := s.GetHeadObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if xconv.Dereference(head.ContentLength) > maxCopyObjectSize {
+		return "", fmt.Errorf("object is larger than %d bytes", maxCopyObjectSize)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(key),
+		CopySource:         aws.String(copySource(s.bucket, key)),
+		CopySourceIfMatch:  head.ETag,
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		TaggingDirective:   types.TaggingDirectiveCopy,
+		ACL:                s.ACL,
+		Metadata:           head.Metadata,
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+		Expires:            head.Expires,
+		StorageClass:       head.StorageClass,
+	}
+	if update.Metadata != nil {
+		input.Metadata = update.Metadata
+	}
+	if update.ContentType != "" {
+		input.ContentType = aws.String(update.ContentType)
+	}
+	if update.CacheControl != "" {
+		input.CacheControl = aws.String(update.CacheControl)
+	}
+
+	output, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return "", ErrObjectChanged
+		}
+		return "", fmt.Errorf("s3.CopyObject: %w", err)
+	}
+	if output.CopyObjectResult == nil {
+		return "", nil
+	}
+	return xconv.Dereference(output.CopyObjectResult.ETag), nil
+}
+
+// S3TagFilter reports whether an object with tags should be kept
+type S3TagFilter func(tags map[string]string) bool
+
+// TagEquals matches objects whose tag of key is value
+func TagEquals(key, value string) S3TagFilter {
+	return func(tags map[string]string) bool {
+		v, ok := tags[key]
+		return ok && v == value
+	}
+}
+
+// HasTag matches objects with tag of key
+func HasTag(key string) S3TagFilter {
+	return func(tags map[string]string) bool {
+		_, ok := tags[key]
+		return ok
+	}
+}
+
+// AllTags matches objects matched by all filters
+func AllTags(filters ...S3TagFilter) S3TagFilter {
+	return func(tags map[string]string) bool {
+		for _, f := range filters {
+			if !f(tags) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FilterByTags fetches tags of objects concurrently and returns objects matched by filter in the original order.
+// Objects deleted after listing are skipped.
+func (s *S3Bucket) FilterByTags(ctx context.Context, objects []*S3ObjectInfo, filter S3TagFilter) ([]*S3ObjectInfo, error) {
+	var mu sync.Mutex
+	matched := make([]bool, len(objects))
+	err := parallel(ctx, defaultTagConcurrency, len(objects), func(ctx context.Context, i int) error {
+		tags, err := s.GetTags(ctx, objects[i].Key)
+		if err != nil {
+			if errors.Is(err, ErrKeyNotFound) {
+				return nil
+			}
+			return fmt.Errorf("get tags of %s: %w", objects[i].Key, err)
+		}
+		mu.Lock()
+		matched[i] = filter(tags)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*S3ObjectInfo
+	for i, obj := range objects {
+		if matched[i] {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+// ListByTags lists all objects under prefix which are matched by filter
+func (s *S3Bucket) ListByTags(ctx context.Context, prefix string, filter S3TagFilter) ([]*S3ObjectInfo, error) {
+	objects, _, err := s.List(ctx, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.FilterByTags(ctx, objects, filter)
+}
+
+func isNoSuchKey(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey"
+}
@@ -1,12 +1,20 @@
 package aws
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"go.olapie.com/x/xerror"
 
 	"github.com/google/uuid"
@@ -96,3 +104,378 @@ func TestS3_BatchDelete(t *testing.T) {
 	err := r.BatchDelete(ctx, ids)
 	require.NoError(t, err)
 }
+
+func TestS3_PutStream(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	key := uuid.NewString() + ".json"
+	content := []byte(`{"id":"` + uuid.NewString() + `"}`)
+	metadata := map[string]string{"test-key": "test value"}
+	_, err := bucket.PutStream(ctx, key, bytes.NewReader(content), -1, "", metadata)
+	require.NoError(t, err)
+	defer bucket.Delete(ctx, key)
+
+	body, info, err := bucket.GetStream(ctx, key)
+	require.NoError(t, err)
+	defer body.Close()
+	readContent, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, content, readContent)
+	require.Equal(t, int64(len(content)), info.Size)
+	require.Equal(t, "application/json", info.ContentType)
+	require.Equal(t, metadata, info.Metadata)
+}
+
+func TestS3_ListPage(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	keys := []string{prefix + "a", prefix + "b", prefix + "dir/c"}
+	for _, key := range keys {
+		_, err := bucket.Put(ctx, key, []byte(key), nil)
+		require.NoError(t, err)
+	}
+	defer bucket.BatchDelete(ctx, keys)
+
+	page, err := bucket.ListPage(ctx, prefix, "/", "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Objects, 1)
+	require.NotEmpty(t, page.NextToken)
+
+	_, err = bucket.ListPage(ctx, "other/", "/", page.NextToken, 1)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	objects, prefixes, err := bucket.List(ctx, prefix, "/")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, []string{prefix + "dir/"}, prefixes)
+
+	var iterated []string
+	it := bucket.Iterate(ctx, prefix)
+	for it.Next() {
+		iterated = append(iterated, it.Object().Key)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, keys, iterated)
+}
+
+func TestS3_Move(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	src := uuid.NewString() + ".txt"
+	dst := uuid.NewString() + ".txt"
+	content := []byte("content" + uuid.NewString())
+	_, err := bucket.Put(ctx, src, content, map[string]string{"k": "v"})
+	require.NoError(t, err)
+	defer bucket.BatchDelete(ctx, []string{src, dst})
+
+	_, err = bucket.Move(ctx, src, nil, dst, func(options *S3CopyOptions) {
+		options.Metadata = map[string]string{"k": "moved"}
+	})
+	require.NoError(t, err)
+	_, err = bucket.GetHeadObject(ctx, src)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	info, err := bucket.Stat(ctx, dst)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"k": "moved"}, info.Metadata)
+}
+
+func TestS3_Move_Overlapping(t *testing.T) {
+	ctx := context.Background()
+	bucket := newFakeS3Bucket(t, &fakeS3{})
+	_, err := bucket.Put(ctx, "a/1.txt", []byte("1"), map[string]string{"k": "v"})
+	require.NoError(t, err)
+
+	_, err = bucket.Move(ctx, "a/1.txt", bucket, "a/1.txt", func(options *S3CopyOptions) {
+		options.Metadata = map[string]string{"k": "moved"}
+	})
+	require.NoError(t, err)
+	info, err := bucket.Stat(ctx, "a/1.txt")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"k": "moved"}, info.Metadata)
+
+	for _, prefixes := range [][2]string{{"a/", "a/"}, {"a/", "a/b/"}, {"a/b/", "a/"}, {"a/", ""}} {
+		_, err = bucket.MovePrefix(ctx, prefixes[0], nil, prefixes[1])
+		require.Error(t, err, prefixes)
+	}
+	data, err := bucket.Get(ctx, "a/1.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), data)
+
+	n, err := bucket.MovePrefix(ctx, "a/", nil, "b/")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	_, err = bucket.Stat(ctx, "a/1.txt")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	data, err = bucket.Get(ctx, "b/1.txt")
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), data)
+}
+
+func TestS3_Copy_ReplaceMetadata(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	src := uuid.NewString() + ".txt"
+	dst := uuid.NewString() + ".txt"
+	content := bytes.Repeat([]byte("content"), 100)
+	_, err := bucket.PutCompressed(ctx, src, bytes.NewReader(content), "", nil, EncodingGzip, func(input *s3.PutObjectInput) {
+		input.ContentDisposition = aws.String("attachment")
+	})
+	require.NoError(t, err)
+	defer bucket.BatchDelete(ctx, []string{src, dst})
+
+	_, err = bucket.Copy(ctx, src, nil, dst, func(options *S3CopyOptions) {
+		options.ContentType = "text/csv"
+		options.Metadata = map[string]string{"k": "v"}
+	})
+	require.NoError(t, err)
+	head, err := bucket.GetHeadObject(ctx, dst)
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", aws.ToString(head.ContentType))
+	require.Equal(t, EncodingGzip, aws.ToString(head.ContentEncoding))
+	require.Equal(t, "attachment", aws.ToString(head.ContentDisposition))
+	require.Equal(t, "v", head.Metadata["k"])
+	data, err := bucket.Get(ctx, dst)
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+}
+
+func TestS3_PreSignPost(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	post, err := bucket.PreSignPost(ctx, &S3PostPolicy{
+		KeyPrefix:         "uploads/",
+		ContentLengthMax:  1 << 20,
+		ContentTypePrefix: "image/",
+	}, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, post.URL)
+	require.Equal(t, "uploads/${filename}", post.Fields["key"])
+	require.NotEmpty(t, post.Fields["policy"])
+	require.NotEmpty(t, post.Fields["x-amz-signature"])
+}
+
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	require.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestPreSignS3Post(t *testing.T) {
+	creds := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := &S3PostPolicy{Key: "a.txt", SuccessActionStatus: 201}
+	post, err := PreSignS3Post(creds, "us-east-1", "bucket", policy, signTime, signTime.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, "20260102T030405Z", post.Fields["x-amz-date"])
+	require.Equal(t, "AKIDEXAMPLE/20260102/us-east-1/s3/aws4_request", post.Fields["x-amz-credential"])
+	require.Equal(t, "201", post.Fields["success_action_status"])
+
+	_, err = PreSignS3Post(creds, "us-east-1", "bucket", policy, signTime, signTime)
+	require.Error(t, err)
+	_, err = PreSignS3Post(creds, "us-east-1", "bucket", &S3PostPolicy{Key: "a.txt", SuccessActionStatus: 302}, signTime, signTime.Add(time.Minute))
+	require.Error(t, err)
+}
+
+func TestS3_Tags(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	clean, infected := prefix+"clean.txt", prefix+"infected.txt"
+	for _, key := range []string{clean, infected} {
+		_, err := bucket.Put(ctx, key, []byte(key), map[string]string{"k": "v"})
+		require.NoError(t, err)
+		defer bucket.Delete(ctx, key)
+	}
+	require.NoError(t, bucket.PutTags(ctx, clean, map[string]string{"scanned": "clean"}))
+	require.NoError(t, bucket.AddTags(ctx, infected, map[string]string{"scanned": "infected"}))
+
+	tags, err := bucket.GetTags(ctx, clean)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"scanned": "clean"}, tags)
+
+	objects, err := bucket.ListByTags(ctx, prefix, TagEquals("scanned", "clean"))
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, clean, objects[0].Key)
+
+	_, err = bucket.UpdateMetadata(ctx, clean, &S3MetadataUpdate{
+		ContentType:  "text/markdown",
+		CacheControl: "no-cache",
+	})
+	require.NoError(t, err)
+	info, err := bucket.Stat(ctx, clean)
+	require.NoError(t, err)
+	require.Equal(t, "text/markdown", info.ContentType)
+	require.Equal(t, map[string]string{"k": "v"}, info.Metadata)
+	tags, err = bucket.GetTags(ctx, clean)
+	require.NoError(t, err)
+	require.Equal(t, "clean", tags["scanned"])
+
+	require.NoError(t, bucket.DeleteTags(ctx, clean))
+	tags, err = bucket.GetTags(ctx, clean)
+	require.NoError(t, err)
+	require.Empty(t, tags)
+}
+
+func TestS3_Versions(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	key := uuid.NewString()
+	_, err := bucket.Put(ctx, key, []byte("v1"), nil)
+	require.NoError(t, err)
+	_, err = bucket.Put(ctx, key, []byte("v2"), nil)
+	require.NoError(t, err)
+	defer func() {
+		versions, _ := bucket.ListKeyVersions(ctx, key)
+		for _, v := range versions {
+			_ = bucket.DeleteVersion(ctx, key, v.VersionID)
+		}
+	}()
+
+	versions, err := bucket.ListKeyVersions(ctx, key)
+	require.NoError(t, err)
+	if len(versions) < 2 {
+		t.Skip("bucket is not versioned")
+	}
+	require.True(t, versions[0].IsLatest)
+	content, err := bucket.GetVersion(ctx, key, versions[1].VersionID)
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(content))
+
+	_, err = bucket.RestorePreviousVersion(ctx, key)
+	require.NoError(t, err)
+	content, err = bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(content))
+}
+
+func TestS3_Sync(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	defer func() {
+		_, _ = bucket.SyncUp(ctx, fstest.MapFS{}, prefix, func(options *S3SyncOptions) {
+			options.Delete = true
+		})
+	}()
+
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<html></html>")},
+		"css/main.css":  {Data: []byte("body{}")},
+		"notes.tmp":     {Data: []byte("tmp")},
+		"img/logo.webp": {Data: []byte("logo")},
+	}
+	exclude := func(options *S3SyncOptions) {
+		options.Exclude = []string{"*.tmp"}
+	}
+	plan, err := bucket.SyncUp(ctx, fsys, prefix, exclude)
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 3)
+
+	plan, err = bucket.SyncUp(ctx, fsys, prefix, exclude)
+	require.NoError(t, err)
+	require.Empty(t, plan.Ops)
+
+	fsys["index.html"] = &fstest.MapFile{Data: []byte("<html>v2</html>")}
+	delete(fsys, "css/main.css")
+	plan, err = bucket.SyncUp(ctx, fsys, prefix, exclude, func(options *S3SyncOptions) {
+		options.Delete = true
+		options.DryRun = true
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 2)
+	_, err = bucket.Stat(ctx, prefix+"css/main.css")
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "sync")
+	plan, err = bucket.SyncDown(ctx, prefix, dir, func(options *S3SyncOptions) {
+		options.DryRun = true
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 3)
+	_, err = os.Stat(dir)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	plan, err = bucket.SyncDown(ctx, prefix, dir)
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 3)
+	data, err := os.ReadFile(filepath.Join(dir, "css", "main.css"))
+	require.NoError(t, err)
+	require.Equal(t, "body{}", string(data))
+}
+
+func TestS3_SyncUp_PartSize(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{}
+	bucket := newFakeS3Bucket(t, fake)
+	small := bytes.Repeat([]byte("s"), minPartSize)
+	large := bytes.Repeat([]byte("l"), minPartSize+1)
+	fsys := fstest.MapFS{
+		"small.bin": {Data: small},
+		"large.bin": {Data: large},
+	}
+	plan, err := bucket.SyncUp(ctx, fsys, "sync/", func(options *S3SyncOptions) {
+		options.PartSize = minPartSize
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 2)
+
+	require.Equal(t, []string{"POST sync/large.bin?uploads="}, fake.requestsWithPrefix("POST sync/large.bin?uploads"))
+	require.Len(t, fake.requestsWithPrefix("PUT sync/large.bin?"), 2)
+	require.Equal(t, []string{"PUT sync/small.bin?x-id=PutObject"}, fake.requestsWithPrefix("PUT sync/small.bin"))
+	data, err := bucket.Get(ctx, "sync/large.bin")
+	require.NoError(t, err)
+	require.Equal(t, large, data)
+	data, err = bucket.Get(ctx, "sync/small.bin")
+	require.NoError(t, err)
+	require.Equal(t, small, data)
+}
+
+func TestS3_FS(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	for _, key := range []string{"index.html", "templates/a.tmpl", "templates/b.tmpl"} {
+		_, err := bucket.Put(ctx, prefix+key, []byte(key), nil)
+		require.NoError(t, err)
+		defer bucket.Delete(ctx, prefix+key)
+	}
+
+	fsys := bucket.FS(prefix, func(options *S3FSOptions) {
+		options.CacheTTL = time.Minute
+	}).WithContext(ctx)
+	entries, err := fs.ReadDir(fsys, ".")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "index.html", entries[0].Name())
+	require.True(t, entries[1].IsDir())
+
+	info, err := fs.Stat(fsys, "templates")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	_, err = fs.Stat(fsys, "missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	data, err := fs.ReadFile(fsys, "templates/a.tmpl")
+	require.NoError(t, err)
+	require.Equal(t, "templates/a.tmpl", string(data))
+
+	matches, err := fs.Glob(fsys, "templates/*.tmpl")
+	require.NoError(t, err)
+	require.Equal(t, []string{"templates/a.tmpl", "templates/b.tmpl"}, matches)
+
+	f, err := fsys.Open("index.html")
+	require.NoError(t, err)
+	defer f.Close()
+	_, ok := f.(io.ReadSeeker)
+	require.True(t, ok)
+}
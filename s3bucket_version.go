@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"go.olapie.com/x/xconv"
+)
+
+// S3ObjectVersion is a version or a delete marker of an object in a versioned bucket
+type S3ObjectVersion struct {
+	Key            string
+	VersionID      string
+	Size           int64
+	ETag           string
+	LastModified   time.Time
+	IsLatest       bool
+	IsDeleteMarker bool
+}
+
+// ListVersions lists versions and delete markers of all objects under prefix.
+// Versions of the same key are ordered from the newest to the oldest.
+func (s *S3Bucket) ListVersions(ctx context.Context, prefix string) ([]*S3ObjectVersion, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	var versions []*S3ObjectVersion
+	paginator := s3.NewListObjectVersionsPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("s3.ListObjectVersions: %w", err)
+		}
+		for _, v := range output.Versions {
+			versions = append(versions, &S3ObjectVersion{
+				Key:          xconv.Dereference(v.Key),
+				VersionID:    xconv.Dereference(v.VersionId),
+				Size:         xconv.Dereference(v.Size),
+				ETag:         xconv.Dereference(v.ETag),
+				LastModified: xconv.Dereference(v.LastModified),
+				IsLatest:     xconv.Dereference(v.IsLatest),
+			})
+		}
+		for _, m := range output.DeleteMarkers {
+			versions = append(versions, &S3ObjectVersion{
+				Key:            xconv.Dereference(m.Key),
+				VersionID:      xconv.Dereference(m.VersionId),
+				LastModified:   xconv.Dereference(m.LastModified),
+				IsLatest:       xconv.Dereference(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+	}
+
+	// versions and delete markers are returned in separate lists
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// ListKeyVersions lists versions and delete markers of the object, from the newest to the oldest
+func (s *S3Bucket) ListKeyVersions(ctx context.Context, key string) ([]*S3ObjectVersion, error) {
+	versions, err := s.ListVersions(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// prefix matches other keys as well
+	result := versions[:0]
+	for _, v := range versions {
+		if v.Key == key {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// GetVersion returns content of the specific version of the object
+func (s *S3Bucket) GetVersion(ctx context.Context, key, versionID string) ([]byte, error) {
+	content, err := s.Get(ctx, key, func(input *s3.GetObjectInput) {
+		input.VersionId = aws.String(versionID)
+	})
+	if isNoSuchVersion(err) {
+		return nil, ErrKeyNotFound
+	}
+	return content, err
+}
+
+// GetVersionHeadObject returns metadata of the specific version of the object
+func (s *S3Bucket) GetVersionHeadObject(ctx context.Context, key, versionID string) (*s3.HeadObjectOutput, error) {
+	output, err := s.GetHeadObject(ctx, key, func(input *s3.HeadObjectInput) {
+		input.VersionId = aws.String(versionID)
+	})
+	if isNoSuchVersion(err) {
+		return nil, ErrKeyNotFound
+	}
+	return output, err
+}
+
+// DeleteVersion permanently deletes the specific version or delete marker of the object
+func (s *S3Bucket) DeleteVersion(ctx context.Context, key, versionID string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return fmt.Errorf("s3.DeleteObject: %w", err)
+	}
+	return nil
+}
+
+// RestoreVersion copies the specific version back as the current version of the object.
+// It returns the id of the new version.
+func (s *S3Bucket) RestoreVersion(ctx context.Context, key, versionID string) (string, error) {
+	output, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(s.bucket, key) + "?versionId=" + url.QueryEscape(versionID)),
+		ACL:        s.ACL,
+	})
+	if err != nil {
+		if isNoSuchKey(err) || isNoSuchVersion(err) {
+			return "", ErrKeyNotFound
+		}
+		return "", fmt.Errorf("s3.CopyObject: %w", err)
+	}
+	return xconv.Dereference(output.VersionId), nil
+}
+
+// RestorePreviousVersion copies the newest version before the current one back as current.
+// If the object is deleted, the last version before deletion is restored.
+// It returns the id of the new version, or ErrKeyNotFound if there is no previous version.
+func (s *S3Bucket) RestorePreviousVersion(ctx context.Context, key string) (string, error) {
+	versions, err := s.ListKeyVersions(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.IsLatest || v.IsDeleteMarker {
+			continue
+		}
+		return s.RestoreVersion(ctx, key, v.VersionID)
+	}
+	return "", ErrKeyNotFound
+}
+
+func isNoSuchVersion(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchVersion"
+}
@@ -0,0 +1,196 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// S3ContentStore stores content under keys derived from SHA-256 of content, so that identical content is stored once.
+//
+// Layout under prefix:
+//
+//	sha256/ab/abcdef...         content
+//	refs/abcdef.../{ref}        references to content, with metadata
+//	tmp/{uuid}                  content larger than 5MB being hashed
+type S3ContentStore struct {
+	bucket *S3Bucket
+	prefix string
+}
+
+func NewS3ContentStore(bucket *S3Bucket, prefix string) *S3ContentStore {
+	return &S3ContentStore{
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Put stores content read from r if it doesn't exist yet. It returns the hex encoded SHA-256 digest of content,
+// and whether the content is newly created. metadata is only stored with newly created content.
+// Content is written only if its key doesn't exist, so concurrent puts of the same content create it once.
+// Content smaller than 5MB is hashed in memory, otherwise it's uploaded to a temporary key while hashing.
+func (c *S3ContentStore) Put(ctx context.Context, r io.Reader, contentType string, metadata map[string]string) (string, bool, error) {
+	head := make([]byte, minPartSize)
+	n, err := io.ReadFull(r, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.putSmall(ctx, head[:n], contentType, metadata)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("read: %w", err)
+	}
+
+	h := sha256.New()
+	tmpKey := c.prefix + "tmp/" + uuid.NewString()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), r), h)
+	if _, err = c.bucket.PutStream(ctx, tmpKey, body, -1, contentType, metadata); err != nil {
+		return "", false, err
+	}
+	defer c.bucket.Delete(context.WithoutCancel(ctx), tmpKey)
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	_, err = c.bucket.Copy(ctx, tmpKey, nil, c.key(digest), func(options *S3CopyOptions) {
+		options.NoOverwrite = true
+	})
+	if err != nil {
+		if errors.Is(err, ErrConditionFailed) {
+			return digest, false, nil
+		}
+		return "", false, fmt.Errorf("copy: %w", err)
+	}
+	return digest, true, nil
+}
+
+func (c *S3ContentStore) putSmall(ctx context.Context, content []byte, contentType string, metadata map[string]string) (string, bool, error) {
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	// S3 verifies content with the checksum header
+	_, err := c.bucket.putStreamWithChecksum(ctx, c.key(digest), bytes.NewReader(content), int64(len(content)), contentType, metadata, ChecksumSHA256, ifNoneMatch)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return digest, false, nil
+		}
+		return "", false, err
+	}
+	return digest, true, nil
+}
+
+// Get returns a stream of content which is verified by digest when reaching the end.
+// *S3ChecksumError is returned by Read if content is corrupted.
+func (c *S3ContentStore) Get(ctx context.Context, digest string) (io.ReadCloser, *S3ObjectInfo, error) {
+	sum, err := parseDigest(digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, info, err := c.bucket.GetStream(ctx, c.key(digest))
+	if err != nil {
+		return nil, nil, err
+	}
+	expected := &S3Checksum{
+		Algorithm: ChecksumSHA256,
+		Value:     base64.StdEncoding.EncodeToString(sum),
+	}
+	info.Checksum = expected
+	return &checksumReader{
+		key:      info.Key,
+		body:     body,
+		hash:     sha256.New(),
+		expected: expected,
+	}, info, nil
+}
+
+// GetBytes returns verified content
+func (c *S3ContentStore) GetBytes(ctx context.Context, digest string) ([]byte, error) {
+	body, _, err := c.Get(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (c *S3ContentStore) Exists(ctx context.Context, digest string) (bool, error) {
+	if _, err := parseDigest(digest); err != nil {
+		return false, err
+	}
+	_, err := c.bucket.GetHeadObject(ctx, c.key(digest))
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes content. References are kept, use ListRefs to check whether content is still referenced.
+func (c *S3ContentStore) Delete(ctx context.Context, digest string) error {
+	if _, err := parseDigest(digest); err != nil {
+		return err
+	}
+	return c.bucket.Delete(ctx, c.key(digest))
+}
+
+// AddRef records that content is referenced by ref, e.g. an upload id or a user id, with metadata
+func (c *S3ContentStore) AddRef(ctx context.Context, digest, ref string, metadata map[string]string) error {
+	if _, err := parseDigest(digest); err != nil {
+		return err
+	}
+	_, err := c.bucket.PutStream(ctx, c.refKey(digest, ref), bytes.NewReader(nil), 0, "", metadata)
+	return err
+}
+
+func (c *S3ContentStore) RemoveRef(ctx context.Context, digest, ref string) error {
+	if _, err := parseDigest(digest); err != nil {
+		return err
+	}
+	return c.bucket.Delete(ctx, c.refKey(digest, ref))
+}
+
+// ListRefs returns references of content with their metadata
+func (c *S3ContentStore) ListRefs(ctx context.Context, digest string) (map[string]map[string]string, error) {
+	if _, err := parseDigest(digest); err != nil {
+		return nil, err
+	}
+	prefix := c.refKey(digest, "")
+	objects, _, err := c.bucket.List(ctx, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]map[string]string, len(objects))
+	for _, obj := range objects {
+		// listing doesn't return metadata
+		info, err := c.bucket.Stat(ctx, obj.Key)
+		if err != nil {
+			if errors.Is(err, ErrKeyNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		refs[strings.TrimPrefix(obj.Key, prefix)] = info.Metadata
+	}
+	return refs, nil
+}
+
+func (c *S3ContentStore) key(digest string) string {
+	return c.prefix + "sha256/" + digest[:2] + "/" + digest
+}
+
+func (c *S3ContentStore) refKey(digest, ref string) string {
+	return c.prefix + "refs/" + digest + "/" + ref
+}
+
+func parseDigest(digest string) ([]byte, error) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size || strings.ToLower(digest) != digest {
+		return nil, fmt.Errorf("invalid digest %q", digest)
+	}
+	return sum, nil
+}
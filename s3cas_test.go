@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_ContentStore(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	store := NewS3ContentStore(bucket, uuid.NewString()+"/")
+	content := []byte("content " + uuid.NewString())
+
+	digest, created, err := store.Put(ctx, bytes.NewReader(content), "text/plain", nil)
+	require.NoError(t, err)
+	require.True(t, created)
+	defer store.Delete(ctx, digest)
+	sum := sha256.Sum256(content)
+	require.Equal(t, hex.EncodeToString(sum[:]), digest)
+
+	digest2, created, err := store.Put(ctx, bytes.NewReader(content), "text/plain", nil)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, digest, digest2)
+
+	data, err := store.GetBytes(ctx, digest)
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	require.NoError(t, store.AddRef(ctx, digest, "user-1", map[string]string{"name": "a.txt"}))
+	defer store.RemoveRef(ctx, digest, "user-1")
+	refs, err := store.ListRefs(ctx, digest)
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]string{"user-1": {"name": "a.txt"}}, refs)
+}
+
+func TestS3ContentStore_Put_Exists(t *testing.T) {
+	ctx := context.Background()
+	for _, size := range []int{100, minPartSize + 100} {
+		fake := &fakeS3{}
+		store := NewS3ContentStore(newFakeS3Bucket(t, fake), "cas/")
+		content := bytes.Repeat([]byte("a"), size)
+
+		digest, created, err := store.Put(ctx, bytes.NewReader(content), "", map[string]string{"n": "1"})
+		require.NoError(t, err, size)
+		require.True(t, created, size)
+
+		// the existing content is kept rather than checked before writing
+		again, created, err := store.Put(ctx, bytes.NewReader(content), "", map[string]string{"n": "2"})
+		require.NoError(t, err, size)
+		require.False(t, created, size)
+		require.Equal(t, digest, again, size)
+		require.Empty(t, fake.requestsWithPrefix("HEAD "+store.key(digest)), size)
+
+		info, err := store.bucket.Stat(ctx, store.key(digest))
+		require.NoError(t, err, size)
+		require.Equal(t, "1", info.Metadata["n"], size)
+		data, err := store.GetBytes(ctx, digest)
+		require.NoError(t, err, size)
+		require.Equal(t, content, data, size)
+
+		objects, _, err := store.bucket.List(ctx, "cas/tmp/", "")
+		require.NoError(t, err, size)
+		require.Empty(t, objects, size)
+	}
+}
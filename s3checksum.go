@@ -0,0 +1,346 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.olapie.com/x/xconv"
+)
+
+type S3ChecksumAlgorithm string
+
+const (
+	ChecksumCRC32C S3ChecksumAlgorithm = "CRC32C"
+	ChecksumSHA256 S3ChecksumAlgorithm = "SHA256"
+
+	// ChecksumMD5 is verified against ETag, which is not MD5 for multipart uploads or objects encrypted by SSE-KMS, DSSE-KMS or SSE-C
+	ChecksumMD5 S3ChecksumAlgorithm = "MD5"
+)
+
+// S3Checksum is a base64 encoded checksum, the same format as S3 checksum headers
+type S3Checksum struct {
+	Algorithm S3ChecksumAlgorithm
+	Value     string
+}
+
+func (c *S3Checksum) String() string {
+	return string(c.Algorithm) + ":" + c.Value
+}
+
+// S3ChecksumError is returned when content doesn't match its checksum
+type S3ChecksumError struct {
+	Key       string
+	Algorithm S3ChecksumAlgorithm
+	Expected  string
+	Actual    string
+}
+
+func (e *S3ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch of %s: expected %s, got %s", e.Algorithm, e.Key, e.Expected, e.Actual)
+}
+
+// S3PutResult is the result of an upload with checksum
+type S3PutResult struct {
+	ETag     string
+	Checksum *S3Checksum
+}
+
+// PutWithChecksum uploads content with checksum computed by algorithm. S3 rejects content which doesn't match the checksum.
+func (s *S3Bucket) PutWithChecksum(ctx context.Context, key string, content []byte, metadata map[string]string, algorithm S3ChecksumAlgorithm) (*S3PutResult, error) {
+	return s.PutStreamWithChecksum(ctx, key, bytes.NewReader(content), int64(len(content)), "", metadata, algorithm)
+}
+
+// PutStreamWithChecksum uploads content read from r with checksum computed by algorithm. size must not be negative.
+// If r is an io.ReadSeeker, the checksum is computed before uploading and sent as a header, so that S3 rejects corrupted content.
+// Otherwise, CRC32C and SHA256 are sent as trailers verified by S3, while MD5 is computed while uploading and
+// compared with the ETag returned by S3. A mismatched object is deleted and *S3ChecksumError is returned.
+func (s *S3Bucket) PutStreamWithChecksum(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string, algorithm S3ChecksumAlgorithm) (*S3PutResult, error) {
+	return s.putStreamWithChecksum(ctx, key, r, size, contentType, metadata, algorithm)
+}
+
+func (s *S3Bucket) putStreamWithChecksum(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string, algorithm S3ChecksumAlgorithm, optFns ...func(*s3.Options)) (*S3PutResult, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("size of content is required")
+	}
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var checksum *S3Checksum
+	body := r
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if checksum, err = checksumOf(rs, algorithm, h); err != nil {
+			return nil, err
+		}
+	} else {
+		body = io.TeeReader(r, h)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ACL:           s.ACL,
+		CacheControl:  aws.String(s.CacheControl),
+		ContentType:   aws.String(contentTypeOf(key, contentType)),
+		Metadata:      metadata,
+	}
+	switch algorithm {
+	case ChecksumCRC32C:
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32c
+	case ChecksumSHA256:
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	if checksum != nil {
+		setChecksumHeader(checksum, &input.ChecksumCRC32C, &input.ChecksumSHA256, &input.ContentMD5)
+	}
+
+	output, err := s.client.PutObject(ctx, input, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("s3.PutObject: %w", err)
+	}
+	if checksum == nil {
+		checksum = &S3Checksum{
+			Algorithm: algorithm,
+			Value:     base64.StdEncoding.EncodeToString(h.Sum(nil)),
+		}
+	}
+
+	etag := xconv.Dereference(output.ETag)
+	// checksum computed by S3
+	var stored string
+	switch algorithm {
+	case ChecksumCRC32C:
+		stored = xconv.Dereference(output.ChecksumCRC32C)
+	case ChecksumSHA256:
+		stored = xconv.Dereference(output.ChecksumSHA256)
+	case ChecksumMD5:
+		if isETagMD5(output.ServerSideEncryption, output.SSECustomerAlgorithm) {
+			stored = md5OfETag(etag)
+		}
+	}
+	if stored != "" && stored != checksum.Value {
+		// corrupted content must not be left in the bucket.
+		// In a versioned bucket, only the uploaded version is deleted rather than whatever is current.
+		if versionID := xconv.Dereference(output.VersionId); versionID != "" {
+			err = s.DeleteVersion(context.WithoutCancel(ctx), key, versionID)
+		} else {
+			err = s.Delete(context.WithoutCancel(ctx), key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("delete corrupted object: %w", err)
+		}
+		return nil, &S3ChecksumError{
+			Key:       key,
+			Algorithm: algorithm,
+			Expected:  checksum.Value,
+			Actual:    stored,
+		}
+	}
+	return &S3PutResult{
+		ETag:     etag,
+		Checksum: checksum,
+	}, nil
+}
+
+// UploadPartWithChecksum uploads a part with checksum. The multipart upload must be created with the same checksum algorithm
+// unless it's ChecksumMD5. The returned part can be passed to CompleteMultipartUpload.
+func (s *S3Bucket) UploadPartWithChecksum(ctx context.Context, key, uploadID string, part int, content []byte, algorithm S3ChecksumAlgorithm) (types.CompletedPart, error) {
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return types.CompletedPart{}, err
+	}
+	h.Write(content)
+	checksum := &S3Checksum{
+		Algorithm: algorithm,
+		Value:     base64.StdEncoding.EncodeToString(h.Sum(nil)),
+	}
+	output, err := s.UploadPart(ctx, key, uploadID, part, content, func(input *s3.UploadPartInput) {
+		setChecksumHeader(checksum, &input.ChecksumCRC32C, &input.ChecksumSHA256, &input.ContentMD5)
+	})
+	if err != nil {
+		return types.CompletedPart{}, fmt.Errorf("s3.UploadPart: %w", err)
+	}
+	completed := types.CompletedPart{
+		ETag:       output.ETag,
+		PartNumber: aws.Int32(int32(part)),
+	}
+	switch algorithm {
+	case ChecksumCRC32C:
+		completed.ChecksumCRC32C = aws.String(checksum.Value)
+	case ChecksumSHA256:
+		completed.ChecksumSHA256 = aws.String(checksum.Value)
+	}
+	return completed, nil
+}
+
+// GetWithChecksum downloads the object and verifies it with the stored checksum.
+// The checksum is set in S3ObjectInfo.Checksum, which is nil if no checksum can be verified.
+func (s *S3Bucket) GetWithChecksum(ctx context.Context, key string) ([]byte, *S3ObjectInfo, error) {
+	body, info, err := s.GetStreamWithChecksum(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, info, nil
+}
+
+// GetStreamWithChecksum returns a stream which verifies content with the stored checksum when reaching the end.
+// *S3ChecksumError is returned by Read instead of io.EOF if content is corrupted.
+// Checksums of multipart uploads are computed from parts, which can't be verified by the stream.
+// Content compressed by PutCompressed is verified as stored, then decompressed.
+func (s *S3Bucket) GetStreamWithChecksum(ctx context.Context, key string) (io.ReadCloser, *S3ObjectInfo, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, nil, ErrKeyNotFound
+		}
+		return nil, nil, fmt.Errorf("s3.GetObject: %w", err)
+	}
+
+	info := &S3ObjectInfo{
+		Key:          key,
+		Size:         xconv.Dereference(output.ContentLength),
+		ETag:         xconv.Dereference(output.ETag),
+		ContentType:  xconv.Dereference(output.ContentType),
+		Metadata:     output.Metadata,
+		LastModified: xconv.Dereference(output.LastModified),
+
+		ContentEncoding: xconv.Dereference(output.ContentEncoding),
+	}
+	switch {
+	case output.ChecksumCRC32C != nil:
+		info.Checksum = &S3Checksum{Algorithm: ChecksumCRC32C, Value: *output.ChecksumCRC32C}
+	case output.ChecksumSHA256 != nil:
+		info.Checksum = &S3Checksum{Algorithm: ChecksumSHA256, Value: *output.ChecksumSHA256}
+	case isETagMD5(output.ServerSideEncryption, output.SSECustomerAlgorithm) && md5OfETag(info.ETag) != "":
+		info.Checksum = &S3Checksum{Algorithm: ChecksumMD5, Value: md5OfETag(info.ETag)}
+	}
+	// checksum of multipart upload is followed by the number of parts, e.g. "xxx-3"
+	if info.Checksum == nil || strings.Contains(info.Checksum.Value, "-") {
+		info.Checksum = nil
+		return decompressBody(output.Body, info)
+	}
+
+	h, _ := newChecksumHash(info.Checksum.Algorithm)
+	return decompressBody(&checksumReader{
+		key:      key,
+		body:     output.Body,
+		hash:     h,
+		expected: info.Checksum,
+	}, info)
+}
+
+func newChecksumHash(algorithm S3ChecksumAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+}
+
+// checksumOf computes the checksum of rs from its current position, then seeks back
+func checksumOf(rs io.ReadSeeker, algorithm S3ChecksumAlgorithm, h hash.Hash) (*S3Checksum, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+	if _, err = io.Copy(h, rs); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+	return &S3Checksum{
+		Algorithm: algorithm,
+		Value:     base64.StdEncoding.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func setChecksumHeader(checksum *S3Checksum, crc32c, sha256, md5 **string) {
+	switch checksum.Algorithm {
+	case ChecksumCRC32C:
+		*crc32c = aws.String(checksum.Value)
+	case ChecksumSHA256:
+		*sha256 = aws.String(checksum.Value)
+	case ChecksumMD5:
+		*md5 = aws.String(checksum.Value)
+	}
+}
+
+// isETagMD5 reports whether ETag of an object uploaded with a single request is MD5 of its content,
+// which is not true for objects encrypted by SSE-KMS, DSSE-KMS or SSE-C
+func isETagMD5(sse types.ServerSideEncryption, sseCustomerAlgorithm *string) bool {
+	return (sse == "" || sse == types.ServerSideEncryptionAes256) && sseCustomerAlgorithm == nil
+}
+
+// md5OfETag returns base64 encoded MD5 in etag, or empty string if etag is not MD5
+func md5OfETag(etag string) string {
+	etag = strings.Trim(etag, `"`)
+	if strings.Contains(etag, "-") {
+		return ""
+	}
+	sum, err := hex.DecodeString(etag)
+	if err != nil || len(sum) != md5.Size {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// checksumReader verifies content with expected checksum when reaching the end
+type checksumReader struct {
+	key      string
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected *S3Checksum
+	verified bool
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && !r.verified {
+		r.verified = true
+		actual := base64.StdEncoding.EncodeToString(r.hash.Sum(nil))
+		if actual != r.expected.Value {
+			return n, &S3ChecksumError{
+				Key:       r.key,
+				Algorithm: r.expected.Algorithm,
+				Expected:  r.expected.Value,
+				Actual:    actual,
+			}
+		}
+	}
+	return n, err
+}
+
+func (r *checksumReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,103 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_Checksum(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	for _, algorithm := range []S3ChecksumAlgorithm{ChecksumCRC32C, ChecksumSHA256, ChecksumMD5} {
+		key := uuid.NewString()
+		content := []byte("checksum " + key)
+		result, err := bucket.PutWithChecksum(ctx, key, content, nil, algorithm)
+		require.NoError(t, err)
+		require.Equal(t, algorithm, result.Checksum.Algorithm)
+		data, info, err := bucket.GetWithChecksum(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+		require.Equal(t, result.Checksum, info.Checksum)
+		require.NoError(t, bucket.Delete(ctx, key))
+	}
+}
+
+func TestChecksumReader(t *testing.T) {
+	h, err := newChecksumHash(ChecksumSHA256)
+	require.NoError(t, err)
+	r := &checksumReader{
+		key:      "k",
+		body:     io.NopCloser(bytes.NewReader([]byte("corrupted"))),
+		hash:     h,
+		expected: &S3Checksum{Algorithm: ChecksumSHA256, Value: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="},
+	}
+	_, err = io.ReadAll(r)
+	var checksumErr *S3ChecksumError
+	require.ErrorAs(t, err, &checksumErr)
+	require.Equal(t, "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=", checksumErr.Expected)
+
+	require.Equal(t, "1B2M2Y8AsgTpgAmY7PhCfg==", md5OfETag(`"d41d8cd98f00b204e9800998ecf8427e"`))
+	require.Empty(t, md5OfETag(`"d41d8cd98f00b204e9800998ecf8427e-2"`))
+
+	// compressed content is verified as stored
+	var buf bytes.Buffer
+	zw, err := gzipCodec{}.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	h, err = newChecksumHash(ChecksumSHA256)
+	require.NoError(t, err)
+	body, _, err := decompressBody(&checksumReader{
+		key:      "k",
+		body:     io.NopCloser(&buf),
+		hash:     h,
+		expected: &S3Checksum{Algorithm: ChecksumSHA256, Value: "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="},
+	}, &S3ObjectInfo{ContentEncoding: EncodingGzip, Metadata: map[string]string{metaCompression: EncodingGzip}})
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	require.ErrorAs(t, err, &checksumErr)
+}
+
+func TestS3_PutStreamWithChecksum_Mismatch(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{versioned: true}
+	bucket := newFakeS3Bucket(t, fake)
+	// content is corrupted on the way to S3
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut {
+			r.Body = io.NopCloser(bytes.NewReader([]byte("corrupted")))
+		}
+		return false
+	}
+	content := []byte("checksum content")
+	// unseekable content is verified after uploading
+	unseekable := func() io.Reader {
+		return io.MultiReader(bytes.NewReader(content))
+	}
+
+	_, err := bucket.PutStreamWithChecksum(ctx, "k", unseekable(), int64(len(content)), "", nil, ChecksumMD5)
+	var checksumErr *S3ChecksumError
+	require.ErrorAs(t, err, &checksumErr)
+	require.Equal(t, "k", checksumErr.Key)
+	require.Equal(t, []string{"DELETE k?versionId=v1&x-id=DeleteObject"}, fake.requestsWithPrefix("DELETE"))
+	_, err = bucket.Stat(ctx, "k")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// ETag is not MD5 of content encrypted by KMS
+	for _, sse := range []types.ServerSideEncryption{types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse} {
+		fake.sse = string(sse)
+		_, err = bucket.PutStreamWithChecksum(ctx, "k", unseekable(), int64(len(content)), "", nil, ChecksumMD5)
+		require.NoError(t, err, sse)
+	}
+	require.Len(t, fake.requestsWithPrefix("DELETE"), 1)
+}
@@ -0,0 +1,195 @@
+package aws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+
+	// metadata keys of compressed objects
+	metaCompression      = "x-compression"
+	metaUncompressedSize = "x-uncompressed-size"
+)
+
+// Codec compresses and decompresses content of an encoding
+type Codec interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		EncodingGzip: gzipCodec{},
+		EncodingZstd: zstdCodec{},
+	}
+)
+
+// RegisterCodec makes a codec available to PutCompressed and GetStream
+func RegisterCodec(encoding string, codec Codec) {
+	codecsMu.Lock()
+	codecs[encoding] = codec
+	codecsMu.Unlock()
+}
+
+func getCodec(encoding string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[encoding]
+	return c, ok
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+// PutCompressed compresses content read from r with the codec of encoding while uploading.
+// Content-Encoding is set to encoding. The original size is stored in metadata if the compressed content fits in one part,
+// otherwise it's unknown and reported as -1.
+// GetStream and Get decompress the content, ranged reads return compressed bytes.
+func (s *S3Bucket) PutCompressed(ctx context.Context, key string, r io.Reader, contentType string, metadata map[string]string, encoding string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	return s.putCompressed(ctx, key, r, -1, contentType, metadata, encoding, optFns...)
+}
+
+// putCompressed stores size as the original size if it's not negative, and fails if r doesn't have size bytes
+func (s *S3Bucket) putCompressed(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string, encoding string, optFns ...func(input *s3.PutObjectInput)) (string, error) {
+	codec, ok := getCodec(encoding)
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding %s", encoding)
+	}
+
+	meta := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[metaCompression] = encoding
+	if size >= 0 {
+		meta[metaUncompressedSize] = strconv.FormatInt(size, 10)
+	}
+	optFns = append([]func(input *s3.PutObjectInput){func(input *s3.PutObjectInput) {
+		input.ContentEncoding = aws.String(encoding)
+	}}, optFns...)
+
+	counter := &countingReader{r: r}
+	pr, pw := io.Pipe()
+	go func() {
+		zw, err := codec.NewWriter(pw)
+		if err == nil {
+			_, err = io.Copy(zw, counter)
+			if err == nil && size >= 0 && counter.n != size {
+				err = fmt.Errorf("read %d bytes, expected %d", counter.n, size)
+			}
+			if closeErr := zw.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	// content compressed into one part is uploaded with its original size
+	head := make([]byte, minPartSize)
+	n, err := io.ReadFull(pr, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		meta[metaUncompressedSize] = strconv.FormatInt(counter.n, 10)
+		return s.putStream(ctx, key, bytes.NewReader(head[:n]), int64(n), contentTypeOf(key, contentType), meta, optFns...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("compress: %w", err)
+	}
+	return s.putStream(ctx, key, io.MultiReader(bytes.NewReader(head), pr), -1, contentTypeOf(key, contentType), meta, optFns...)
+}
+
+// decompressBody wraps body with a decompressing reader if it's compressed by PutCompressed.
+// Size of info is set to the original size, or -1 if it's unknown.
+func decompressBody(body io.ReadCloser, info *S3ObjectInfo) (io.ReadCloser, *S3ObjectInfo, error) {
+	decoded, ok := decompressedInfo(info)
+	if !ok {
+		return body, info, nil
+	}
+	codec, ok := getCodec(decoded.ContentEncoding)
+	if !ok {
+		body.Close()
+		return nil, nil, fmt.Errorf("unsupported encoding %s", decoded.ContentEncoding)
+	}
+	zr, err := codec.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, nil, fmt.Errorf("decompress: %w", err)
+	}
+	return &decompressReader{
+		ReadCloser: zr,
+		body:       body,
+	}, decoded, nil
+}
+
+// decompressedInfo returns a copy of info with the original size if the object is compressed by PutCompressed.
+// Size is -1 if it's unknown.
+func decompressedInfo(info *S3ObjectInfo) (*S3ObjectInfo, bool) {
+	encoding := info.Metadata[metaCompression]
+	if encoding == "" || encoding != info.ContentEncoding {
+		return info, false
+	}
+	decoded := *info
+	decoded.Size = -1
+	if size, err := strconv.ParseInt(info.Metadata[metaUncompressedSize], 10, 64); err == nil {
+		decoded.Size = size
+	}
+	return &decoded, true
+}
+
+type decompressReader struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (r *decompressReader) Close() error {
+	err := r.ReadCloser.Close()
+	if bodyErr := r.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
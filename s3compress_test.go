@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_PutCompressed(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	key := uuid.NewString() + ".json"
+	content := []byte(strings.Repeat(`{"name":"test"}`, 1000))
+	_, err := bucket.PutCompressed(ctx, key, bytes.NewReader(content), "", nil, EncodingGzip)
+	require.NoError(t, err)
+	defer bucket.Delete(ctx, key)
+
+	data, err := bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	body, info, err := bucket.GetRawStream(ctx, key)
+	require.NoError(t, err)
+	defer body.Close()
+	require.Equal(t, EncodingGzip, info.ContentEncoding)
+	require.Less(t, info.Size, int64(len(content)))
+}
+
+func TestDecompressBody(t *testing.T) {
+	content := []byte(strings.Repeat("compressible ", 100))
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			codec, ok := getCodec(encoding)
+			require.True(t, ok)
+			var buf bytes.Buffer
+			zw, err := codec.NewWriter(&buf)
+			require.NoError(t, err)
+			_, err = zw.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			body, info, err := decompressBody(io.NopCloser(&buf), &S3ObjectInfo{
+				Size:            int64(buf.Len()),
+				ContentEncoding: encoding,
+				Metadata: map[string]string{
+					metaCompression:      encoding,
+					metaUncompressedSize: "1300",
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, int64(len(content)), info.Size)
+			data, err := io.ReadAll(body)
+			require.NoError(t, err)
+			require.NoError(t, body.Close())
+			require.Equal(t, content, data)
+		})
+	}
+
+	_, _, err := decompressBody(io.NopCloser(bytes.NewReader(nil)), &S3ObjectInfo{
+		ContentEncoding: "br",
+		Metadata:        map[string]string{metaCompression: "br"},
+	})
+	require.Error(t, err)
+}
+
+func TestS3_CompressedFS(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	bucket.Compression = EncodingGzip
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	content := []byte(strings.Repeat("0123456789", 1000))
+	_, err := bucket.Put(ctx, prefix+"data.txt", content, nil)
+	require.NoError(t, err)
+	defer bucket.Delete(ctx, prefix+"data.txt")
+
+	fsys := bucket.FS(prefix).WithContext(ctx)
+	info, err := fs.Stat(fsys, "data.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), info.Size())
+	data, err := fs.ReadFile(fsys, "data.txt")
+	require.NoError(t, err)
+	require.Equal(t, content, data)
+
+	f, err := fsys.Open("data.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	require.True(t, ok)
+	head := make([]byte, 512)
+	_, err = io.ReadFull(rs, head)
+	require.NoError(t, err)
+	size, err := rs.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), size)
+	_, err = rs.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+	data, err = io.ReadAll(rs)
+	require.NoError(t, err)
+	require.Equal(t, content[5:], data)
+}
+
+func TestS3_SyncUp_Compression(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	bucket.Compression = EncodingGzip
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	defer func() {
+		_, _ = bucket.SyncUp(ctx, fstest.MapFS{}, prefix, func(options *S3SyncOptions) {
+			options.Delete = true
+		})
+	}()
+
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte(strings.Repeat("<p></p>", 100))},
+	}
+	plan, err := bucket.SyncUp(ctx, fsys, prefix)
+	require.NoError(t, err)
+	require.Len(t, plan.Ops, 1)
+	plan, err = bucket.SyncUp(ctx, fsys, prefix)
+	require.NoError(t, err)
+	require.Empty(t, plan.Ops)
+}
@@ -0,0 +1,435 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	encryptionAlgorithm = "AES-256-GCM-CHUNKED"
+
+	// metadata keys of encrypted objects, S3 returns metadata keys in lower case
+	metaEncryptionAlgorithm = "x-enc-alg"
+	metaEncryptionKey       = "x-enc-key"
+	metaEncryptionKeyID     = "x-enc-key-id"
+	metaEncryptionChunkSize = "x-enc-chunk-size"
+
+	defaultEncryptionChunkSize = 64 << 10
+	gcmTagSize                 = 16
+)
+
+// KeyProvider generates and unwraps data keys of encrypted objects
+type KeyProvider interface {
+	// GenerateDataKey returns a new 256-bit data key, its wrapped form and the id of the wrapping key
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, keyID string, err error)
+
+	// DecryptDataKey unwraps a data key generated by GenerateDataKey
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// StaticKeyProvider wraps data keys with a local master key using AES-GCM
+type StaticKeyProvider struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var _ KeyProvider = (*StaticKeyProvider)(nil)
+
+// NewStaticKeyProvider creates a StaticKeyProvider. key must be 16, 24 or 32 bytes.
+// id is stored with objects, so that master keys can be rotated.
+func NewStaticKeyProvider(id string, key []byte) (*StaticKeyProvider, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM: %w", err)
+	}
+	return &StaticKeyProvider{
+		id:   id,
+		aead: aead,
+	}, nil
+}
+
+func (p *StaticKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, "", fmt.Errorf("rand.Read: %w", err)
+	}
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, "", fmt.Errorf("rand.Read: %w", err)
+	}
+	wrapped := p.aead.Seal(nonce, nonce, key, []byte(p.id))
+	return key, wrapped, p.id, nil
+}
+
+func (p *StaticKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.id {
+		return nil, fmt.Errorf("unknown key id %s", keyID)
+	}
+	if len(wrapped) < p.aead.NonceSize() {
+		return nil, errors.New("invalid wrapped key")
+	}
+	nonce, ciphertext := wrapped[:p.aead.NonceSize()], wrapped[p.aead.NonceSize():]
+	key, err := p.aead.Open(nil, nonce, ciphertext, []byte(p.id))
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	return key, nil
+}
+
+type S3EncryptionOptions struct {
+	// ChunkSize is the size of plaintext encrypted with a single nonce. Larger chunk makes ranged reads less efficient.
+	ChunkSize int
+}
+
+// S3EncryptedBucket encrypts objects before uploading and decrypts them after downloading.
+// Each object has its own data key which is wrapped by KeyProvider and stored in object metadata.
+// Content is split into chunks which are sealed with AES-GCM separately, so that ranges can be decrypted independently.
+type S3EncryptedBucket struct {
+	bucket  *S3Bucket
+	keys    KeyProvider
+	options *S3EncryptionOptions
+}
+
+func NewS3EncryptedBucket(bucket *S3Bucket, keys KeyProvider, optFns ...func(options *S3EncryptionOptions)) *S3EncryptedBucket {
+	b := &S3EncryptedBucket{
+		bucket: bucket,
+		keys:   keys,
+		options: &S3EncryptionOptions{
+			ChunkSize: defaultEncryptionChunkSize,
+		},
+	}
+	for _, fn := range optFns {
+		fn(b.options)
+	}
+	if b.options.ChunkSize <= 0 {
+		b.options.ChunkSize = defaultEncryptionChunkSize
+	}
+	return b
+}
+
+// Bucket returns the underlying bucket
+func (b *S3EncryptedBucket) Bucket() *S3Bucket {
+	return b.bucket
+}
+
+// Put encrypts content and uploads it
+func (b *S3EncryptedBucket) Put(ctx context.Context, key string, content []byte, metadata map[string]string) (string, error) {
+	return b.PutStream(ctx, key, bytes.NewReader(content), int64(len(content)), "", metadata)
+}
+
+// PutStream encrypts content read from r and uploads it. size is the length of plaintext, which can be negative if it's unknown.
+func (b *S3EncryptedBucket) PutStream(ctx context.Context, key string, r io.Reader, size int64, contentType string, metadata map[string]string) (string, error) {
+	dataKey, wrapped, keyID, err := b.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("generateDataKey: %w", err)
+	}
+	aead, err := newDataKeyAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	meta := make(map[string]string, len(metadata)+4)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[metaEncryptionAlgorithm] = encryptionAlgorithm
+	meta[metaEncryptionKey] = base64.StdEncoding.EncodeToString(wrapped)
+	meta[metaEncryptionKeyID] = keyID
+	meta[metaEncryptionChunkSize] = strconv.Itoa(b.options.ChunkSize)
+
+	encryptedSize := int64(-1)
+	if size >= 0 {
+		encryptedSize = encryptedSizeOf(size, int64(b.options.ChunkSize))
+	}
+	er := &encryptReader{
+		aead:  aead,
+		r:     bufio.NewReader(r),
+		chunk: make([]byte, b.options.ChunkSize),
+	}
+	// encrypted content is not compressible
+	return b.bucket.putStream(ctx, key, er, encryptedSize, contentTypeOf(key, contentType), meta)
+}
+
+// Get downloads and decrypts the object of key
+func (b *S3EncryptedBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := b.GetStream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return content, nil
+}
+
+// GetStream returns a reader of decrypted content. Info contains the plaintext size and user metadata.
+// Authentication errors are returned by Read.
+func (b *S3EncryptedBucket) GetStream(ctx context.Context, key string) (io.ReadCloser, *S3ObjectInfo, error) {
+	body, info, err := b.bucket.GetRawStream(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := b.openHeader(ctx, info)
+	if err != nil {
+		body.Close()
+		return nil, nil, err
+	}
+	return &decryptReader{
+		header: header,
+		body:   body,
+		remain: info.Size,
+		skip:   0,
+		limit:  header.plainSize,
+	}, header.info, nil
+}
+
+// GetRange returns a reader of decrypted content in [off, off+length).
+// Only the chunks covering the range are downloaded.
+func (b *S3EncryptedBucket) GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error) {
+	info, err := b.bucket.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	header, err := b.openHeader(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	if off < 0 || length < 0 || off+length > header.plainSize {
+		return nil, fmt.Errorf("range %d-%d is out of size %d", off, off+length-1, header.plainSize)
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	chunkSize := int64(header.chunkSize)
+	sealedSize := chunkSize + gcmTagSize
+	first := off / chunkSize
+	last := (off + length - 1) / chunkSize
+	sealedOff := first * sealedSize
+	sealedLen := min((last+1)*sealedSize, info.Size) - sealedOff
+	body, err := b.bucket.getRange(ctx, key, info.ETag, sealedOff, sealedLen)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		header: header,
+		body:   body,
+		index:  uint64(first),
+		remain: info.Size - sealedOff,
+		skip:   off - first*chunkSize,
+		limit:  length,
+	}, nil
+}
+
+// Stat returns the plaintext size and user metadata of the object
+func (b *S3EncryptedBucket) Stat(ctx context.Context, key string) (*S3ObjectInfo, error) {
+	info, err := b.bucket.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	size, err := plainSizeOf(info)
+	if err != nil {
+		return nil, err
+	}
+	return stripEncryptionMetadata(info, size), nil
+}
+
+type encryptionHeader struct {
+	aead      cipher.AEAD
+	chunkSize int
+	plainSize int64
+	info      *S3ObjectInfo
+}
+
+func (b *S3EncryptedBucket) openHeader(ctx context.Context, info *S3ObjectInfo) (*encryptionHeader, error) {
+	plainSize, err := plainSizeOf(info)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(info.Metadata[metaEncryptionKey])
+	if err != nil {
+		return nil, fmt.Errorf("decode wrapped key: %w", err)
+	}
+	dataKey, err := b.keys.DecryptDataKey(ctx, info.Metadata[metaEncryptionKeyID], wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("decryptDataKey: %w", err)
+	}
+	aead, err := newDataKeyAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize, _ := strconv.Atoi(info.Metadata[metaEncryptionChunkSize])
+	return &encryptionHeader{
+		aead:      aead,
+		chunkSize: chunkSize,
+		plainSize: plainSize,
+		info:      stripEncryptionMetadata(info, plainSize),
+	}, nil
+}
+
+func plainSizeOf(info *S3ObjectInfo) (int64, error) {
+	if info.Metadata[metaEncryptionAlgorithm] != encryptionAlgorithm {
+		return 0, ErrNotEncrypted
+	}
+	chunkSize, err := strconv.ParseInt(info.Metadata[metaEncryptionChunkSize], 10, 64)
+	if err != nil || chunkSize <= 0 {
+		return 0, fmt.Errorf("invalid chunk size %q", info.Metadata[metaEncryptionChunkSize])
+	}
+	sealedSize := chunkSize + gcmTagSize
+	n := (info.Size + sealedSize - 1) / sealedSize
+	if n == 0 || info.Size-(n-1)*sealedSize < gcmTagSize {
+		return 0, fmt.Errorf("invalid encrypted size %d", info.Size)
+	}
+	return info.Size - n*gcmTagSize, nil
+}
+
+func stripEncryptionMetadata(info *S3ObjectInfo, plainSize int64) *S3ObjectInfo {
+	stripped := *info
+	stripped.Size = plainSize
+	stripped.Metadata = make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		switch k {
+		case metaEncryptionAlgorithm, metaEncryptionKey, metaEncryptionKeyID, metaEncryptionChunkSize:
+		default:
+			stripped.Metadata[k] = v
+		}
+	}
+	return &stripped
+}
+
+// encryptedSizeOf returns the size of sealed content. Empty content has a single empty chunk.
+func encryptedSizeOf(size, chunkSize int64) int64 {
+	n := max(1, (size+chunkSize-1)/chunkSize)
+	return size + n*gcmTagSize
+}
+
+func newDataKeyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM: %w", err)
+	}
+	return aead, nil
+}
+
+// chunkNonce is made of chunk index and a flag of the final chunk, which prevents reordering and truncation
+func chunkNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if final {
+		nonce[8] = 1
+	}
+	return nonce
+}
+
+type encryptReader struct {
+	aead   cipher.AEAD
+	r      *bufio.Reader
+	chunk  []byte
+	index  uint64
+	sealed []byte
+	done   bool
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.sealed) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.r, e.chunk)
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			e.done = true
+		case err != nil:
+			return 0, err
+		default:
+			if _, err = e.r.Peek(1); err == io.EOF {
+				e.done = true
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		e.sealed = e.aead.Seal(e.sealed[:0], chunkNonce(e.index, e.done), e.chunk[:n], nil)
+		e.index++
+	}
+	n := copy(p, e.sealed)
+	e.sealed = e.sealed[n:]
+	return n, nil
+}
+
+type decryptReader struct {
+	header *encryptionHeader
+	body   io.ReadCloser
+	index  uint64
+	// remain is the size of sealed content from current chunk to the end of object
+	remain int64
+	// skip is the number of plaintext bytes to discard in the first chunk
+	skip  int64
+	limit int64
+	plain []byte
+	buf   []byte
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.limit <= 0 {
+		return 0, io.EOF
+	}
+	for len(d.plain) == 0 {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain[:min(int64(len(d.plain)), d.limit)])
+	d.plain = d.plain[n:]
+	d.limit -= int64(n)
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	sealedSize := min(int64(d.header.chunkSize+gcmTagSize), d.remain)
+	if sealedSize < gcmTagSize {
+		return io.ErrUnexpectedEOF
+	}
+	if cap(d.buf) < int(sealedSize) {
+		d.buf = make([]byte, d.header.chunkSize+gcmTagSize)
+	}
+	sealed := d.buf[:sealedSize]
+	if _, err := io.ReadFull(d.body, sealed); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	d.remain -= sealedSize
+	plain, err := d.header.aead.Open(sealed[:0], chunkNonce(d.index, d.remain == 0), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("decrypt chunk %d: %w", d.index, err)
+	}
+	d.index++
+	d.plain = plain[min(d.skip, int64(len(plain))):]
+	d.skip = 0
+	return nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.body.Close()
+}
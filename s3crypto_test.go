@@ -0,0 +1,113 @@
+package aws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3EncryptedBucket(t *testing.T) {
+	keys, err := NewStaticKeyProvider("test", make([]byte, 32))
+	require.NoError(t, err)
+	bucket := NewS3EncryptedBucket(setupS3Bucket(t), keys, func(options *S3EncryptionOptions) {
+		options.ChunkSize = 1024
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	key := uuid.NewString()
+	content := make([]byte, 5000)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+	_, err = bucket.Put(ctx, key, content, map[string]string{"name": "test"})
+	require.NoError(t, err)
+	defer bucket.Bucket().Delete(ctx, key)
+
+	got, err := bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+
+	info, err := bucket.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), info.Size)
+	require.Equal(t, map[string]string{"name": "test"}, info.Metadata)
+
+	r, err := bucket.GetRange(ctx, key, 1000, 2100)
+	require.NoError(t, err)
+	defer r.Close()
+	got, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, content[1000:3100], got)
+}
+
+func TestEncryptReader(t *testing.T) {
+	keys, err := NewStaticKeyProvider("test", make([]byte, 32))
+	require.NoError(t, err)
+	dataKey, wrapped, keyID, err := keys.GenerateDataKey(context.Background())
+	require.NoError(t, err)
+	unwrapped, err := keys.DecryptDataKey(context.Background(), keyID, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+	aead, err := newDataKeyAEAD(dataKey)
+	require.NoError(t, err)
+
+	const chunkSize = 16
+	for _, size := range []int{0, 1, 15, 16, 17, 32, 100} {
+		content := make([]byte, size)
+		_, err = rand.Read(content)
+		require.NoError(t, err)
+		sealed, err := io.ReadAll(&encryptReader{
+			aead:  aead,
+			r:     bufio.NewReader(bytes.NewReader(content)),
+			chunk: make([]byte, chunkSize),
+		})
+		require.NoError(t, err)
+		require.Equal(t, encryptedSizeOf(int64(size), chunkSize), int64(len(sealed)))
+
+		info := &S3ObjectInfo{
+			Size: int64(len(sealed)),
+			Metadata: map[string]string{
+				metaEncryptionAlgorithm: encryptionAlgorithm,
+				metaEncryptionChunkSize: "16",
+			},
+		}
+		plainSize, err := plainSizeOf(info)
+		require.NoError(t, err)
+		require.Equal(t, int64(size), plainSize)
+
+		header := &encryptionHeader{aead: aead, chunkSize: chunkSize, plainSize: plainSize}
+		for off := 0; off < size; off += 7 {
+			length := min(20, size-off)
+			first := int64(off / chunkSize)
+			sealedOff := first * (chunkSize + gcmTagSize)
+			got, err := io.ReadAll(&decryptReader{
+				header: header,
+				body:   io.NopCloser(bytes.NewReader(sealed[sealedOff:])),
+				index:  uint64(first),
+				remain: int64(len(sealed)) - sealedOff,
+				skip:   int64(off) - first*chunkSize,
+				limit:  int64(length),
+			})
+			require.NoError(t, err)
+			require.Equal(t, content[off:off+length], got)
+		}
+
+		// truncated content must not be decrypted
+		if size > chunkSize {
+			_, err = io.ReadAll(&decryptReader{
+				header: header,
+				body:   io.NopCloser(bytes.NewReader(sealed[:chunkSize+gcmTagSize])),
+				remain: chunkSize + gcmTagSize,
+				limit:  chunkSize,
+			})
+			require.Error(t, err)
+		}
+	}
+}
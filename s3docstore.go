@@ -0,0 +1,222 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.olapie.com/x/xconv"
+)
+
+const defaultDocumentRetries = 5
+
+// S3PreconditionError is returned when a conditional write fails because the document was created or changed by others
+type S3PreconditionError struct {
+	Key string
+
+	// ETag is the expected ETag, which is empty if the document was expected not to exist
+	ETag string
+
+	err error
+}
+
+func (e *S3PreconditionError) Error() string {
+	if e.ETag == "" {
+		return fmt.Sprintf("document %s already exists", e.Key)
+	}
+	return fmt.Sprintf("document %s is not at %s", e.Key, e.ETag)
+}
+
+func (e *S3PreconditionError) Unwrap() error {
+	return e.err
+}
+
+func (e *S3PreconditionError) Is(target error) bool {
+	return target == ErrConditionFailed
+}
+
+func (e *S3PreconditionError) Code() int {
+	return http.StatusPreconditionFailed
+}
+
+type S3DocumentStoreOptions struct {
+	// MaxRetries is the number of retries of Update after conflicts
+	MaxRetries int
+}
+
+// S3Document is a document with the ETag it's read at
+type S3Document[T any] struct {
+	Key          string
+	ETag         string
+	LastModified time.Time
+	Value        *T
+}
+
+// S3DocumentStore reads and writes values of T as JSON documents under prefix.
+// Writes can be conditional on ETags, so that concurrent updates don't overwrite each other.
+type S3DocumentStore[T any] struct {
+	bucket  *S3Bucket
+	prefix  string
+	options *S3DocumentStoreOptions
+}
+
+func NewS3DocumentStore[T any](bucket *S3Bucket, prefix string, optFns ...func(options *S3DocumentStoreOptions)) *S3DocumentStore[T] {
+	options := &S3DocumentStoreOptions{
+		MaxRetries: defaultDocumentRetries,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	return &S3DocumentStore[T]{
+		bucket:  bucket,
+		prefix:  prefix,
+		options: options,
+	}
+}
+
+// Get returns the document of key, or ErrKeyNotFound
+func (d *S3DocumentStore[T]) Get(ctx context.Context, key string) (*S3Document[T], error) {
+	body, info, err := d.bucket.GetStream(ctx, d.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	value := new(T)
+	if err = json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return &S3Document[T]{
+		Key:          key,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+		Value:        value,
+	}, nil
+}
+
+// Put writes the document unconditionally and returns its new ETag
+func (d *S3DocumentStore[T]) Put(ctx context.Context, key string, value *T) (string, error) {
+	return d.put(ctx, key, value, "", "")
+}
+
+// Create writes the document only if it doesn't exist, otherwise *S3PreconditionError is returned
+func (d *S3DocumentStore[T]) Create(ctx context.Context, key string, value *T) (string, error) {
+	return d.put(ctx, key, value, "If-None-Match", "*")
+}
+
+// Replace writes the document only if its current ETag is etag, otherwise *S3PreconditionError is returned
+func (d *S3DocumentStore[T]) Replace(ctx context.Context, key string, value *T, etag string) (string, error) {
+	if etag == "" {
+		return "", errors.New("missing etag")
+	}
+	return d.put(ctx, key, value, "If-Match", etag)
+}
+
+func (d *S3DocumentStore[T]) Delete(ctx context.Context, key string) error {
+	return d.bucket.Delete(ctx, d.prefix+key)
+}
+
+// Update reads the document, calls mutate and writes it back if it's not changed in between.
+// exists is false if the document doesn't exist, then value is the zero value of T.
+// mutate is called again with the latest document after a conflict, so it must not have side effects.
+// An error returned by mutate aborts the update.
+func (d *S3DocumentStore[T]) Update(ctx context.Context, key string, mutate func(value *T, exists bool) error) (*S3Document[T], error) {
+	backoff := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		doc, err := d.Get(ctx, key)
+		exists := err == nil
+		if err != nil {
+			if !errors.Is(err, ErrKeyNotFound) {
+				return nil, err
+			}
+			doc = &S3Document[T]{
+				Key:   key,
+				Value: new(T),
+			}
+		}
+
+		if err = mutate(doc.Value, exists); err != nil {
+			return nil, err
+		}
+
+		if exists {
+			doc.ETag, err = d.Replace(ctx, key, doc.Value, doc.ETag)
+		} else {
+			doc.ETag, err = d.Create(ctx, key, doc.Value)
+		}
+		if err == nil {
+			doc.LastModified = time.Now()
+			return doc, nil
+		}
+		if !errors.Is(err, ErrConditionFailed) || attempt >= d.options.MaxRetries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *S3DocumentStore[T]) put(ctx context.Context, key string, value *T, condition, etag string) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(d.bucket.bucket),
+		Key:           aws.String(d.prefix + key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ACL:           d.bucket.ACL,
+		CacheControl:  aws.String("no-cache"),
+		ContentType:   aws.String("application/json"),
+	}
+
+	var optFns []func(*s3.Options)
+	if condition != "" {
+		// conditional headers are not modeled by the sdk version in use
+		optFns = append(optFns, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue(condition, etag))
+		})
+	}
+
+	output, err := d.bucket.client.PutObject(ctx, input, optFns...)
+	if err != nil {
+		if isPreconditionFailed(err) || isConditionalRequestConflict(err) {
+			preconditionErr := &S3PreconditionError{
+				Key: key,
+				err: err,
+			}
+			if condition == "If-Match" {
+				preconditionErr.ETag = etag
+			}
+			return "", preconditionErr
+		}
+		return "", fmt.Errorf("s3.PutObject: %w", err)
+	}
+	return xconv.Dereference(output.ETag), nil
+}
+
+func isConditionalRequestConflict(err error) bool {
+	var apiError smithy.APIError
+	return errors.As(err, &apiError) && apiError.ErrorCode() == "ConditionalRequestConflict"
+}
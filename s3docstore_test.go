@@ -0,0 +1,45 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_DocumentStore(t *testing.T) {
+	type config struct {
+		Version int `json:"version"`
+	}
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	store := NewS3DocumentStore[config](bucket, uuid.NewString()+"/")
+	defer store.Delete(ctx, "config.json")
+
+	etag, err := store.Create(ctx, "config.json", &config{Version: 1})
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "config.json", &config{Version: 1})
+	require.ErrorIs(t, err, ErrConditionFailed)
+
+	_, err = store.Replace(ctx, "config.json", &config{Version: 2}, etag)
+	require.NoError(t, err)
+	_, err = store.Replace(ctx, "config.json", &config{Version: 3}, etag)
+	var preconditionErr *S3PreconditionError
+	require.ErrorAs(t, err, &preconditionErr)
+	require.Equal(t, etag, preconditionErr.ETag)
+
+	doc, err := store.Update(ctx, "config.json", func(c *config, exists bool) error {
+		require.True(t, exists)
+		c.Version++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, doc.Value.Version)
+
+	doc, err = store.Get(ctx, "config.json")
+	require.NoError(t, err)
+	require.Equal(t, 3, doc.Value.Version)
+}
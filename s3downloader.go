@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	defaultDownloadPartSize    = 8 << 20
+	defaultDownloadConcurrency = 4
+	defaultDownloadRetries     = 3
+)
+
+type S3DownloaderOptions struct {
+	// PartSize is the size of each ranged request
+	PartSize int64
+
+	// Concurrency is the number of ranges downloaded in parallel
+	Concurrency int
+
+	// MaxRetries is the number of retries of a failed range
+	MaxRetries int
+}
+
+// S3Downloader downloads an object in concurrent ranges
+type S3Downloader struct {
+	bucket  *S3Bucket
+	options *S3DownloaderOptions
+}
+
+func NewS3Downloader(bucket *S3Bucket, optFns ...func(options *S3DownloaderOptions)) *S3Downloader {
+	d := &S3Downloader{
+		bucket: bucket,
+		options: &S3DownloaderOptions{
+			PartSize:    defaultDownloadPartSize,
+			Concurrency: defaultDownloadConcurrency,
+			MaxRetries:  defaultDownloadRetries,
+		},
+	}
+
+	for _, fn := range optFns {
+		fn(d.options)
+	}
+
+	if d.options.PartSize <= 0 {
+		d.options.PartSize = defaultDownloadPartSize
+	}
+
+	if d.options.Concurrency <= 0 {
+		d.options.Concurrency = 1
+	}
+
+	if d.options.MaxRetries < 0 {
+		d.options.MaxRetries = 0
+	}
+	return d
+}
+
+// Download writes the object of key into w.
+// All ranges are requested with the ETag read at the beginning,
+// ErrObjectChanged is returned if the object is changed during downloading.
+func (d *S3Downloader) Download(ctx context.Context, key string, w io.WriterAt) (*S3ObjectInfo, error) {
+	info, err := d.bucket.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	n := int((info.Size + d.options.PartSize - 1) / d.options.PartSize)
+	err = parallel(ctx, d.options.Concurrency, n, func(ctx context.Context, i int) error {
+		off := int64(i) * d.options.PartSize
+		return d.downloadRange(ctx, info, off, min(d.options.PartSize, info.Size-off), w)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (d *S3Downloader) downloadRange(ctx context.Context, info *S3ObjectInfo, off, length int64, w io.WriterAt) error {
+	backoff := 200 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := d.copyRange(ctx, info, off, length, w)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrObjectChanged) || errors.Is(err, ErrKeyNotFound) ||
+			attempt >= d.options.MaxRetries || ctx.Err() != nil {
+			return fmt.Errorf("range %d-%d: %w", off, off+length-1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *S3Downloader) copyRange(ctx context.Context, info *S3ObjectInfo, off, length int64, w io.WriterAt) error {
+	body, err := d.bucket.getRange(ctx, info.Key, info.ETag, off, length)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	n, err := io.Copy(io.NewOffsetWriter(w, off), body)
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+	if n != length {
+		return fmt.Errorf("expect %d bytes, got %d", length, n)
+	}
+	return nil
+}
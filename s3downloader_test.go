@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Downloader_Download(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	key := uuid.NewString()
+	content := make([]byte, 3<<20+17)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	_, err = bucket.Put(ctx, key, content, nil)
+	require.NoError(t, err)
+	defer bucket.Delete(ctx, key)
+
+	f, err := os.CreateTemp(t.TempDir(), "download")
+	require.NoError(t, err)
+	defer f.Close()
+	downloader := NewS3Downloader(bucket, func(options *S3DownloaderOptions) {
+		options.PartSize = 1 << 20
+	})
+	info, err := downloader.Download(ctx, key, f)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), info.Size)
+	readContent, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, content, readContent)
+
+	r, err := bucket.NewObjectReader(ctx, key, func(options *S3ObjectReaderOptions) {
+		options.BlockSize = 1 << 20
+	})
+	require.NoError(t, err)
+	_, err = r.Seek(-100, io.SeekEnd)
+	require.NoError(t, err)
+	tail, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(content[len(content)-100:], tail))
+}
@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// S3ObjectEvent is a record of an S3 event notification
+type S3ObjectEvent struct {
+	// EventName is the type of event, e.g. "ObjectCreated:Put", "ObjectRemoved:Delete"
+	EventName string
+	EventTime time.Time
+	Bucket    string
+
+	// Key is URL-decoded
+	Key  string
+	Size int64
+
+	// ETag is quoted as in S3ObjectInfo, while it's unquoted in records
+	ETag      string
+	VersionID string
+
+	// Source is the bucket added to the router with the same name, or nil
+	Source *S3Bucket
+
+	Record *events.S3EventRecord
+}
+
+type S3EventHandler func(ctx context.Context, event *S3ObjectEvent) error
+
+// S3EventRecordError is the error of handling a record
+type S3EventRecordError struct {
+	EventName string
+	Bucket    string
+	Key       string
+	Err       error
+}
+
+func (e *S3EventRecordError) Error() string {
+	return fmt.Sprintf("%s %s/%s: %v", e.EventName, e.Bucket, e.Key, e.Err)
+}
+
+func (e *S3EventRecordError) Unwrap() error {
+	return e.Err
+}
+
+// S3EventError is returned by S3EventRouter.HandleEvent if any record failed
+type S3EventError struct {
+	Records []*S3EventRecordError
+}
+
+func (e *S3EventError) Error() string {
+	if len(e.Records) == 1 {
+		return e.Records[0].Error()
+	}
+	return fmt.Sprintf("%d records failed, first: %v", len(e.Records), e.Records[0])
+}
+
+func (e *S3EventError) Unwrap() []error {
+	errs := make([]error, len(e.Records))
+	for i, r := range e.Records {
+		errs[i] = r
+	}
+	return errs
+}
+
+type s3EventRoute struct {
+	eventName string
+	prefix    string
+	suffix    string
+	handler   S3EventHandler
+}
+
+func (r *s3EventRoute) match(eventName, key string) bool {
+	if !strings.HasPrefix(key, r.prefix) || !strings.HasSuffix(key, r.suffix) {
+		return false
+	}
+	if r.eventName == "*" {
+		return true
+	}
+	if group, ok := strings.CutSuffix(r.eventName, "*"); ok {
+		return strings.HasPrefix(eventName, group)
+	}
+	return eventName == r.eventName
+}
+
+// S3EventRouter dispatches records of S3 event notifications to handlers, e.g.
+//
+//	router := NewS3EventRouter()
+//	router.Handle("s3:ObjectCreated:*", "uploads/", ".jpg", makeThumbnail)
+//	lambda.Start(router.HandleEvent)
+type S3EventRouter struct {
+	routes  []*s3EventRoute
+	buckets map[string]*S3Bucket
+}
+
+func NewS3EventRouter() *S3EventRouter {
+	return &S3EventRouter{
+		buckets: make(map[string]*S3Bucket),
+	}
+}
+
+// Handle registers handler for records of eventName whose keys have prefix and suffix.
+// eventName is as in notification configurations, e.g. "s3:ObjectCreated:Put", "s3:ObjectCreated:*" or "*".
+// A record is dispatched to the first matching route in order of registration.
+func (r *S3EventRouter) Handle(eventName, prefix, suffix string, handler S3EventHandler) {
+	r.routes = append(r.routes, &s3EventRoute{
+		eventName: strings.TrimPrefix(eventName, "s3:"),
+		prefix:    prefix,
+		suffix:    suffix,
+		handler:   handler,
+	})
+}
+
+// AddBucket sets bucket as S3ObjectEvent.Source of records from the bucket with the same name
+func (r *S3EventRouter) AddBucket(bucket *S3Bucket) {
+	r.buckets[bucket.bucket] = bucket
+}
+
+// HandleEvent dispatches all records, records without a matching route are ignored.
+// Failures of records don't stop handling others, they are returned as *S3EventError.
+func (r *S3EventRouter) HandleEvent(ctx context.Context, event events.S3Event) error {
+	var failed []*S3EventRecordError
+	for i := range event.Records {
+		record := &event.Records[i]
+		key, err := recordKey(record)
+		if err == nil {
+			err = r.handleRecord(ctx, record, key)
+		}
+		if err != nil {
+			failed = append(failed, &S3EventRecordError{
+				EventName: record.EventName,
+				Bucket:    record.S3.Bucket.Name,
+				Key:       key,
+				Err:       err,
+			})
+		}
+	}
+	if len(failed) > 0 {
+		return &S3EventError{Records: failed}
+	}
+	return nil
+}
+
+// recordKey returns the decoded key of record, or the raw key with an error if it can't be decoded.
+func recordKey(record *events.S3EventRecord) (string, error) {
+	if key := record.S3.Object.URLDecodedKey; key != "" {
+		return key, nil
+	}
+	// URLDecodedKey is only set when the record is decoded from json
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		return record.S3.Object.Key, fmt.Errorf("decode key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *S3EventRouter) handleRecord(ctx context.Context, record *events.S3EventRecord, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, route := range r.routes {
+		if !route.match(record.EventName, key) {
+			continue
+		}
+		return route.handler(ctx, &S3ObjectEvent{
+			EventName: record.EventName,
+			EventTime: record.EventTime,
+			Bucket:    record.S3.Bucket.Name,
+			Key:       key,
+			Size:      record.S3.Object.Size,
+			ETag:      quoteETag(record.S3.Object.ETag),
+			VersionID: record.S3.Object.VersionID,
+			Source:    r.buckets[record.S3.Bucket.Name],
+			Record:    record,
+		})
+	}
+	return nil
+}
+
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+)
+
+const testS3Event = `{
+  "Records": [
+    {
+      "eventName": "ObjectCreated:Put",
+      "s3": {
+        "bucket": {"name": "uploads"},
+        "object": {"key": "images/my+photo%281%29.jpg", "size": 1024, "eTag": "0123456789abcdef0123456789abcdef"}
+      }
+    },
+    {
+      "eventName": "ObjectCreated:CompleteMultipartUpload",
+      "s3": {
+        "bucket": {"name": "uploads"},
+        "object": {"key": "docs/my+report.pdf", "size": 2048, "eTag": "fedcba9876543210fedcba9876543210-2"}
+      }
+    },
+    {
+      "eventName": "ObjectRemoved:Delete",
+      "s3": {
+        "bucket": {"name": "uploads"},
+        "object": {"key": "images/b.jpg"}
+      }
+    },
+    {
+      "eventName": "ObjectCreated:Copy",
+      "s3": {
+        "bucket": {"name": "other"},
+        "object": {"key": "images/c.jpg", "size": 1}
+      }
+    }
+  ]
+}`
+
+func TestS3EventRouter(t *testing.T) {
+	var event events.S3Event
+	require.NoError(t, json.Unmarshal([]byte(testS3Event), &event))
+
+	bucket := &S3Bucket{bucket: "uploads"}
+	router := NewS3EventRouter()
+	router.AddBucket(bucket)
+
+	var images []*S3ObjectEvent
+	router.Handle("s3:ObjectCreated:*", "images/", ".jpg", func(ctx context.Context, e *S3ObjectEvent) error {
+		images = append(images, e)
+		return nil
+	})
+	failure := errors.New("failure")
+	router.Handle("s3:ObjectCreated:CompleteMultipartUpload", "", "", func(ctx context.Context, e *S3ObjectEvent) error {
+		return failure
+	})
+	var removed []string
+	router.Handle("ObjectRemoved:*", "", "", func(ctx context.Context, e *S3ObjectEvent) error {
+		removed = append(removed, e.Key)
+		return nil
+	})
+
+	err := router.HandleEvent(context.Background(), event)
+	var eventErr *S3EventError
+	require.ErrorAs(t, err, &eventErr)
+	require.Len(t, eventErr.Records, 1)
+	require.Equal(t, "docs/my report.pdf", eventErr.Records[0].Key)
+	require.ErrorIs(t, err, failure)
+
+	require.Len(t, images, 2)
+	require.Equal(t, "images/my photo(1).jpg", images[0].Key)
+	require.Equal(t, int64(1024), images[0].Size)
+	require.Equal(t, `"0123456789abcdef0123456789abcdef"`, images[0].ETag)
+	require.Same(t, bucket, images[0].Source)
+	require.Equal(t, "other", images[1].Bucket)
+	require.Nil(t, images[1].Source)
+	require.Equal(t, []string{"images/b.jpg"}, removed)
+}
@@ -0,0 +1,443 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type S3FSOptions struct {
+	// CacheTTL is how long directory listings are cached. Listings are not cached if it's zero.
+	CacheTTL time.Duration
+
+	// ReaderOptions are applied to readers of opened files
+	ReaderOptions []func(options *S3ObjectReaderOptions)
+}
+
+// S3FS is a read-only file system of objects under a prefix.
+// Keys are split by "/" into directories, objects whose keys end with "/" are treated as directory markers.
+// Opened files implement io.ReadSeeker and io.ReaderAt, so that they can be served by http.FileServer.
+// Objects compressed by PutCompressed are decompressed, their opened files implement io.ReadSeeker only,
+// and seeking backwards reads from the beginning again. Sizes in directory listings are stored sizes.
+type S3FS struct {
+	ctx     context.Context
+	bucket  *S3Bucket
+	prefix  string
+	options *S3FSOptions
+	cache   *s3DirCache
+}
+
+var (
+	_ fs.ReadDirFS  = (*S3FS)(nil)
+	_ fs.StatFS     = (*S3FS)(nil)
+	_ fs.ReadFileFS = (*S3FS)(nil)
+	_ fs.SubFS      = (*S3FS)(nil)
+)
+
+// FS returns a file system rooted at prefix, which is treated as a directory
+func (s *S3Bucket) FS(prefix string, optFns ...func(options *S3FSOptions)) *S3FS {
+	options := &S3FSOptions{}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3FS{
+		ctx:     context.Background(),
+		bucket:  s,
+		prefix:  prefix,
+		options: options,
+		cache: &s3DirCache{
+			entries: make(map[string]*s3DirCacheEntry),
+		},
+	}
+}
+
+// WithContext returns a copy of f whose requests are made with ctx. The listing cache is shared.
+func (f *S3FS) WithContext(ctx context.Context) *S3FS {
+	c := *f
+	c.ctx = ctx
+	return &c
+}
+
+func (f *S3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		info, err := f.bucket.Stat(f.ctx, f.prefix+name)
+		if err == nil {
+			if decoded, ok := decompressedInfo(info); ok {
+				return &s3CompressedFile{
+					ctx:    f.ctx,
+					bucket: f.bucket,
+					info:   newS3FileInfo(decoded),
+					size:   decoded.Size,
+				}, nil
+			}
+			return &s3File{
+				S3ObjectReader: f.bucket.newObjectReader(f.ctx, info, f.options.ReaderOptions...),
+				info:           newS3FileInfo(info),
+			}, nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &s3Dir{
+		info:    newS3DirInfo(name),
+		entries: entries,
+	}, nil
+}
+
+func (f *S3FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		info, err := f.bucket.Stat(f.ctx, f.prefix+name)
+		if err == nil {
+			info, _ = decompressedInfo(info)
+			return newS3FileInfo(info), nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+	}
+	if _, err := f.readDir(name); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return newS3DirInfo(name), nil
+}
+
+func (f *S3FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *S3FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	content, err := f.bucket.Get(f.ctx, f.prefix+name)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return content, nil
+}
+
+func (f *S3FS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return f, nil
+	}
+	sub := *f
+	sub.prefix = f.prefix + dir + "/"
+	sub.cache = &s3DirCache{
+		entries: make(map[string]*s3DirCacheEntry),
+	}
+	return &sub, nil
+}
+
+// readDir lists the directory. An empty directory doesn't exist as there is no object under it.
+func (f *S3FS) readDir(name string) ([]fs.DirEntry, error) {
+	dirPrefix := f.prefix
+	if name != "." {
+		dirPrefix += name + "/"
+	}
+	if entries, ok := f.cache.get(dirPrefix); ok {
+		return entries, nil
+	}
+
+	objects, prefixes, err := f.bucket.List(f.ctx, dirPrefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(objects)+len(prefixes))
+	for _, obj := range objects {
+		// directory marker
+		if obj.Key == dirPrefix {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(newS3FileInfo(obj)))
+	}
+	for _, p := range prefixes {
+		entries = append(entries, fs.FileInfoToDirEntry(newS3DirInfo(strings.TrimSuffix(p, "/"))))
+	}
+	if len(entries) == 0 && len(objects) == 0 && name != "." {
+		return nil, fs.ErrNotExist
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	if f.options.CacheTTL > 0 {
+		f.cache.put(dirPrefix, entries, time.Now().Add(f.options.CacheTTL))
+	}
+	return entries, nil
+}
+
+type s3DirCacheEntry struct {
+	entries []fs.DirEntry
+	expires time.Time
+}
+
+type s3DirCache struct {
+	mu      sync.Mutex
+	entries map[string]*s3DirCacheEntry
+}
+
+func (c *s3DirCache) get(dirPrefix string) ([]fs.DirEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[dirPrefix]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, dirPrefix)
+		return nil, false
+	}
+	return e.entries, true
+}
+
+func (c *s3DirCache) put(dirPrefix string, entries []fs.DirEntry, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[dirPrefix] = &s3DirCacheEntry{
+		entries: entries,
+		expires: expires,
+	}
+}
+
+// s3FileInfo implements fs.FileInfo. Sys returns *S3ObjectInfo of files.
+type s3FileInfo struct {
+	name   string
+	object *S3ObjectInfo
+}
+
+func newS3FileInfo(obj *S3ObjectInfo) *s3FileInfo {
+	return &s3FileInfo{
+		name:   path.Base(obj.Key),
+		object: obj,
+	}
+}
+
+func newS3DirInfo(name string) *s3FileInfo {
+	return &s3FileInfo{
+		name: path.Base(name),
+	}
+}
+
+func (i *s3FileInfo) Name() string {
+	return i.name
+}
+
+func (i *s3FileInfo) Size() int64 {
+	if i.object == nil {
+		return 0
+	}
+	return i.object.Size
+}
+
+func (i *s3FileInfo) Mode() fs.FileMode {
+	if i.object == nil {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i *s3FileInfo) ModTime() time.Time {
+	if i.object == nil {
+		return time.Time{}
+	}
+	return i.object.LastModified
+}
+
+func (i *s3FileInfo) IsDir() bool {
+	return i.object == nil
+}
+
+func (i *s3FileInfo) Sys() any {
+	return i.object
+}
+
+type s3File struct {
+	*S3ObjectReader
+	info *s3FileInfo
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3File) Close() error {
+	return nil
+}
+
+// s3CompressedFile streams decompressed content from offset, the stream is reopened when seeking backwards
+type s3CompressedFile struct {
+	ctx    context.Context
+	bucket *S3Bucket
+	info   *s3FileInfo
+
+	// size is the original size, or -1 until it's found by reading to the end
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+func (f *s3CompressedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3CompressedFile) Read(p []byte) (int, error) {
+	if f.size >= 0 && f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if err := f.seekBody(); err != nil {
+		return 0, err
+	}
+	if f.size >= 0 && f.offset >= f.size {
+		return 0, io.EOF
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	f.bodyPos = f.offset
+	if err == io.EOF {
+		f.size = f.offset
+	}
+	return n, err
+}
+
+func (f *s3CompressedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		if f.size < 0 {
+			// original size is unknown, find it by reading through
+			f.offset = max(f.offset, f.bodyPos)
+			if err := f.seekBody(); err != nil {
+				return 0, err
+			}
+			n, err := io.Copy(io.Discard, f.body)
+			if err != nil {
+				return 0, err
+			}
+			f.bodyPos += n
+			f.size = f.bodyPos
+		}
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// seekBody makes body positioned at offset
+func (f *s3CompressedFile) seekBody() error {
+	if f.body != nil && f.bodyPos > f.offset {
+		f.body.Close()
+		f.body = nil
+	}
+	if f.body == nil {
+		obj := f.info.object
+		body, _, err := f.bucket.GetStream(f.ctx, obj.Key, func(input *s3.GetObjectInput) {
+			input.IfMatch = aws.String(obj.ETag)
+		})
+		if err != nil {
+			if isPreconditionFailed(err) {
+				return ErrObjectChanged
+			}
+			return err
+		}
+		f.body = body
+		f.bodyPos = 0
+	}
+	if f.bodyPos < f.offset {
+		n, err := io.CopyN(io.Discard, f.body, f.offset-f.bodyPos)
+		f.bodyPos += n
+		if err == io.EOF {
+			f.size = f.bodyPos
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3CompressedFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+type s3Dir struct {
+	info    *s3FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*s3Dir)(nil)
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *s3Dir) Close() error {
+	return nil
+}
+
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remain := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remain, nil
+	}
+	if len(remain) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remain))
+	d.offset += n
+	return remain[:n], nil
+}
@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.olapie.com/x/xconv"
+)
+
+const (
+	defaultJanitorMaxAge      = 24 * time.Hour
+	defaultJanitorConcurrency = 8
+)
+
+type S3JanitorOptions struct {
+	// MaxAge is the age after which an upload is aborted. Zero disables the age rule.
+	MaxAge time.Duration
+
+	// Prefixes are key prefixes whose uploads are aborted regardless of age
+	Prefixes []string
+
+	// DryRun only reports uploads which would be aborted in S3JanitorReport.Stale
+	DryRun bool
+
+	Concurrency int
+}
+
+// S3StaleUpload is an incomplete multipart upload found by the janitor
+type S3StaleUpload struct {
+	Key       string    `json:"key"`
+	UploadID  string    `json:"upload_id"`
+	Initiated time.Time `json:"initiated"`
+
+	// Reason is "age" or "prefix"
+	Reason string `json:"reason"`
+
+	// Error is the error of aborting the upload
+	Error string `json:"error,omitempty"`
+}
+
+// S3JanitorReport is the result of a janitor run, which can be returned by a Lambda handler
+type S3JanitorReport struct {
+	Bucket  string           `json:"bucket"`
+	DryRun  bool             `json:"dry_run"`
+	Scanned int              `json:"scanned"`
+	Aborted []*S3StaleUpload `json:"aborted"`
+	Failed  []*S3StaleUpload `json:"failed,omitempty"`
+
+	// Stale lists uploads which would be aborted in a dry run, nothing is aborted then
+	Stale []*S3StaleUpload `json:"stale,omitempty"`
+}
+
+// AbortStaleMultipartUploads aborts incomplete multipart uploads which are older than MaxAge or match Prefixes.
+// Failures of single uploads are reported in S3JanitorReport.Failed instead of stopping the run.
+func (s *S3Bucket) AbortStaleMultipartUploads(ctx context.Context, optFns ...func(options *S3JanitorOptions)) (*S3JanitorReport, error) {
+	options := &S3JanitorOptions{
+		MaxAge:      defaultJanitorMaxAge,
+		Concurrency: defaultJanitorConcurrency,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+
+	uploads, err := s.ListMultipartUploads(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listMultipartUploads: %w", err)
+	}
+
+	report := &S3JanitorReport{
+		Bucket:  s.bucket,
+		DryRun:  options.DryRun,
+		Scanned: len(uploads),
+		Aborted: []*S3StaleUpload{},
+	}
+	now := time.Now()
+	var stale []*S3StaleUpload
+	for _, u := range uploads {
+		if reason := staleReason(u, options, now); reason != "" {
+			stale = append(stale, &S3StaleUpload{
+				Key:       xconv.Dereference(u.Key),
+				UploadID:  xconv.Dereference(u.UploadId),
+				Initiated: xconv.Dereference(u.Initiated),
+				Reason:    reason,
+			})
+		}
+	}
+	if options.DryRun {
+		report.Stale = stale
+		return report, nil
+	}
+
+	var mu sync.Mutex
+	err = parallel(ctx, options.Concurrency, len(stale), func(ctx context.Context, i int) error {
+		u := stale[i]
+		abortErr := s.AbortMultipartUpload(ctx, u.Key, u.UploadID)
+		mu.Lock()
+		defer mu.Unlock()
+		if abortErr != nil {
+			u.Error = abortErr.Error()
+			report.Failed = append(report.Failed, u)
+		} else {
+			report.Aborted = append(report.Aborted, u)
+		}
+		return nil
+	})
+	return report, err
+}
+
+// NewS3JanitorHandler returns a handler for a scheduled Lambda, e.g. lambda.Start(NewS3JanitorHandler(bucket))
+func NewS3JanitorHandler(bucket *S3Bucket, optFns ...func(options *S3JanitorOptions)) func(ctx context.Context) (*S3JanitorReport, error) {
+	return func(ctx context.Context) (*S3JanitorReport, error) {
+		return bucket.AbortStaleMultipartUploads(ctx, optFns...)
+	}
+}
+
+func staleReason(u types.MultipartUpload, options *S3JanitorOptions, now time.Time) string {
+	key := xconv.Dereference(u.Key)
+	for _, prefix := range options.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return "prefix"
+		}
+	}
+	if options.MaxAge > 0 && u.Initiated != nil && now.Sub(*u.Initiated) > options.MaxAge {
+		return "age"
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_AbortStaleMultipartUploads(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	uploadID, err := bucket.CreateMultipartUpload(ctx, prefix+"stale")
+	require.NoError(t, err)
+
+	report, err := bucket.AbortStaleMultipartUploads(ctx, func(options *S3JanitorOptions) {
+		options.MaxAge = 0
+		options.Prefixes = []string{prefix}
+		options.DryRun = true
+	})
+	require.NoError(t, err)
+	require.Empty(t, report.Aborted)
+	require.Len(t, report.Stale, 1)
+	require.Equal(t, uploadID, report.Stale[0].UploadID)
+
+	report, err = bucket.AbortStaleMultipartUploads(ctx, func(options *S3JanitorOptions) {
+		options.MaxAge = 0
+		options.Prefixes = []string{prefix}
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Aborted, 1)
+	require.Empty(t, report.Failed)
+	require.Empty(t, report.Stale)
+	uploads, err := bucket.ListMultipartUploads(ctx, func(input *s3.ListMultipartUploadsInput) {
+		input.Prefix = aws.String(prefix)
+	})
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func TestStaleReason(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name      string
+		key       string
+		initiated *time.Time
+		options   S3JanitorOptions
+		reason    string
+	}{
+		{"old", "a", aws.Time(now.Add(-25 * time.Hour)), S3JanitorOptions{MaxAge: 24 * time.Hour}, "age"},
+		{"recent", "a", aws.Time(now.Add(-23 * time.Hour)), S3JanitorOptions{MaxAge: 24 * time.Hour}, ""},
+		{"unknown initiated", "a", nil, S3JanitorOptions{MaxAge: 24 * time.Hour}, ""},
+		{"age disabled", "a", aws.Time(now.Add(-24 * 365 * time.Hour)), S3JanitorOptions{}, ""},
+		{"prefix", "tmp/a", aws.Time(now), S3JanitorOptions{MaxAge: 24 * time.Hour, Prefixes: []string{"x/", "tmp/"}}, "prefix"},
+		{"prefix before age", "tmp/a", aws.Time(now.Add(-25 * time.Hour)), S3JanitorOptions{MaxAge: 24 * time.Hour, Prefixes: []string{"tmp/"}}, "prefix"},
+		{"prefix unmatched", "data/a", aws.Time(now), S3JanitorOptions{Prefixes: []string{"tmp/"}}, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			u := types.MultipartUpload{Key: aws.String(test.key), Initiated: test.initiated}
+			require.Equal(t, test.reason, staleReason(u, &test.options, now))
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.olapie.com/x/xconv"
+)
+
+const (
+	defaultReaderBlockSize   = 1 << 20
+	defaultReaderCacheBlocks = 8
+)
+
+type S3ObjectReaderOptions struct {
+	// BlockSize is the size of each ranged request
+	BlockSize int64
+
+	// CacheBlocks is the number of recently read blocks kept in memory
+	CacheBlocks int
+}
+
+// S3ObjectReader reads an object with ranged requests. It implements io.ReaderAt and io.ReadSeeker.
+// The object is pinned by the ETag when the reader is created,
+// ErrObjectChanged is returned if the object is changed later.
+type S3ObjectReader struct {
+	ctx     context.Context
+	bucket  *S3Bucket
+	info    *S3ObjectInfo
+	options *S3ObjectReaderOptions
+
+	mu     sync.Mutex
+	offset int64
+	blocks map[int64][]byte
+
+	// recent holds indexes of cached blocks, the most recently used is at the end
+	recent []int64
+}
+
+var (
+	_ io.ReaderAt   = (*S3ObjectReader)(nil)
+	_ io.ReadSeeker = (*S3ObjectReader)(nil)
+)
+
+// NewObjectReader creates a reader of key. ctx is used by all requests made by the reader.
+func (s *S3Bucket) NewObjectReader(ctx context.Context, key string, optFns ...func(options *S3ObjectReaderOptions)) (*S3ObjectReader, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.newObjectReader(ctx, info, optFns...), nil
+}
+
+func (s *S3Bucket) newObjectReader(ctx context.Context, info *S3ObjectInfo, optFns ...func(options *S3ObjectReaderOptions)) *S3ObjectReader {
+	r := &S3ObjectReader{
+		ctx:    ctx,
+		bucket: s,
+		info:   info,
+		options: &S3ObjectReaderOptions{
+			BlockSize:   defaultReaderBlockSize,
+			CacheBlocks: defaultReaderCacheBlocks,
+		},
+		blocks: make(map[int64][]byte),
+	}
+	for _, fn := range optFns {
+		fn(r.options)
+	}
+	if r.options.BlockSize <= 0 {
+		r.options.BlockSize = defaultReaderBlockSize
+	}
+	if r.options.CacheBlocks <= 0 {
+		r.options.CacheBlocks = 1
+	}
+	return r
+}
+
+func (r *S3ObjectReader) Info() *S3ObjectInfo {
+	return r.info
+}
+
+func (r *S3ObjectReader) Size() int64 {
+	return r.info.Size
+}
+
+func (r *S3ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.info.Size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < r.info.Size {
+		index := off / r.options.BlockSize
+		block, err := r.block(index)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off-index*r.options.BlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *S3ObjectReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	off := r.offset
+	r.mu.Unlock()
+	n, err := r.ReadAt(p, off)
+	r.mu.Lock()
+	r.offset = off + int64(n)
+	r.mu.Unlock()
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *S3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *S3ObjectReader) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if b, ok := r.blocks[index]; ok {
+		r.touch(index)
+		r.mu.Unlock()
+		return b, nil
+	}
+	r.mu.Unlock()
+
+	start := index * r.options.BlockSize
+	length := min(r.options.BlockSize, r.info.Size-start)
+	body, err := r.bucket.getRange(r.ctx, r.info.Key, r.info.ETag, start, length)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	b := make([]byte, length)
+	if _, err = io.ReadFull(body, b); err != nil {
+		return nil, fmt.Errorf("read range: %w", err)
+	}
+
+	r.mu.Lock()
+	if _, ok := r.blocks[index]; !ok {
+		r.blocks[index] = b
+		r.recent = append(r.recent, index)
+		if len(r.recent) > r.options.CacheBlocks {
+			delete(r.blocks, r.recent[0])
+			r.recent = r.recent[1:]
+		}
+	}
+	r.mu.Unlock()
+	return b, nil
+}
+
+func (r *S3ObjectReader) touch(index int64) {
+	for i, v := range r.recent {
+		if v == index {
+			r.recent = append(append(r.recent[:i:i], r.recent[i+1:]...), index)
+			return
+		}
+	}
+}
+
+// getRange reads length bytes from off of the object.
+// ErrObjectChanged is returned if etag is not empty and doesn't match the object's.
+func (s *S3Bucket) getRange(ctx context.Context, key, etag string, off, length int64, optFns ...func(*s3.GetObjectInput)) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+length-1)),
+	}
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+	for _, fn := range optFns {
+		fn(input)
+	}
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrKeyNotFound
+		}
+		if isPreconditionFailed(err) {
+			return nil, ErrObjectChanged
+		}
+		return nil, fmt.Errorf("s3.GetObject: %w", err)
+	}
+	if etag != "" && xconv.Dereference(output.ETag) != etag {
+		output.Body.Close()
+		return nil, ErrObjectChanged
+	}
+	return output.Body, nil
+}
+
+func isPreconditionFailed(err error) bool {
+	var apiError smithy.APIError
+	return errors.As(err, &apiError) && apiError.ErrorCode() == "PreconditionFailed"
+}
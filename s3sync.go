@@ -0,0 +1,435 @@
+package aws
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultSyncConcurrency = 8
+
+type S3SyncCompare int
+
+const (
+	// SyncCompareSize treats files of different sizes as changed
+	SyncCompareSize S3SyncCompare = iota
+
+	// SyncCompareETag compares MD5 of local files with ETags. ETags of multipart uploads are not MD5, so sizes are compared instead.
+	SyncCompareETag
+
+	// SyncCompareModTime treats files of different sizes or newer source as changed
+	SyncCompareModTime
+)
+
+type S3SyncAction string
+
+const (
+	SyncUpload   S3SyncAction = "upload"
+	SyncDownload S3SyncAction = "download"
+	SyncDelete   S3SyncAction = "delete"
+)
+
+type S3SyncOptions struct {
+	Compare S3SyncCompare
+
+	// Delete removes files in destination which don't exist in source
+	Delete bool
+
+	// Include and Exclude are path.Match patterns, matched against both the slash-separated relative path and the base name.
+	// If Include is not empty, only matched files are synced. Excluded files are neither synced nor deleted.
+	Include []string
+	Exclude []string
+
+	// DryRun only makes the plan
+	DryRun bool
+
+	Concurrency int
+
+	// PartSize is the size of parts of files uploaded with multipart upload.
+	// Files not larger than a part are uploaded with a single request.
+	PartSize int64
+}
+
+// S3SyncOp is a single step of a sync plan
+type S3SyncOp struct {
+	Action S3SyncAction
+
+	// Path is the slash-separated path relative to the local root
+	Path string
+	Key  string
+	Size int64
+
+	// Reason explains why the file is synced, e.g. "new", "size", "etag", "modtime", "extraneous"
+	Reason string
+}
+
+func (o *S3SyncOp) String() string {
+	if o.Action == SyncDownload {
+		return fmt.Sprintf("%s %s -> %s (%s)", o.Action, o.Key, o.Path, o.Reason)
+	}
+	if o.Action == SyncDelete && o.Key == "" {
+		return fmt.Sprintf("%s %s (%s)", o.Action, o.Path, o.Reason)
+	}
+	if o.Action == SyncDelete {
+		return fmt.Sprintf("%s %s (%s)", o.Action, o.Key, o.Reason)
+	}
+	return fmt.Sprintf("%s %s -> %s (%s)", o.Action, o.Path, o.Key, o.Reason)
+}
+
+// S3SyncPlan lists the operations of a sync, which are done unless DryRun is specified
+type S3SyncPlan struct {
+	Ops []*S3SyncOp
+}
+
+func (p *S3SyncPlan) String() string {
+	lines := make([]string, len(p.Ops))
+	for i, op := range p.Ops {
+		lines[i] = op.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type localFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// SyncUp uploads changed files in fsys to prefix. Use os.DirFS for a local directory.
+// Files are uploaded as is regardless of S3Bucket.Compression, so that sizes and ETags of objects match files.
+func (s *S3Bucket) SyncUp(ctx context.Context, fsys fs.FS, prefix string, optFns ...func(options *S3SyncOptions)) (*S3SyncPlan, error) {
+	options := newS3SyncOptions(optFns...)
+	files, err := walkSyncFiles(fsys, options)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s.listSyncObjects(ctx, prefix, options)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &S3SyncPlan{}
+	for _, f := range files {
+		key := prefix + f.path
+		reason, err := uploadReason(fsys, f, objects[key], options.Compare)
+		if err != nil {
+			return nil, err
+		}
+		delete(objects, key)
+		if reason != "" {
+			plan.Ops = append(plan.Ops, &S3SyncOp{Action: SyncUpload, Path: f.path, Key: key, Size: f.info.Size(), Reason: reason})
+		}
+	}
+	if options.Delete {
+		for _, key := range sortedKeys(objects) {
+			plan.Ops = append(plan.Ops, &S3SyncOp{Action: SyncDelete, Key: key, Size: objects[key].Size, Reason: "extraneous"})
+		}
+	}
+	if options.DryRun {
+		return plan, nil
+	}
+
+	err = parallel(ctx, options.Concurrency, len(plan.Ops), func(ctx context.Context, i int) error {
+		op := plan.Ops[i]
+		if op.Action != SyncUpload {
+			return nil
+		}
+		f, err := fsys.Open(op.Path)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", op.Path, err)
+		}
+		defer f.Close()
+		if err = s.uploadFile(ctx, op.Key, f, op.Size, options.PartSize); err != nil {
+			return fmt.Errorf("upload %s: %w", op.Path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	var keys []string
+	for _, op := range plan.Ops {
+		if op.Action == SyncDelete {
+			keys = append(keys, op.Key)
+		}
+	}
+	for i := 0; i < len(keys); i += maxListKeys {
+		if err = s.BatchDelete(ctx, keys[i:min(i+maxListKeys, len(keys))]); err != nil {
+			return plan, fmt.Errorf("batchDelete: %w", err)
+		}
+	}
+	return plan, nil
+}
+
+// uploadFile uploads files larger than partSize with multipart upload, as a single request can't be larger than 5GB
+func (s *S3Bucket) uploadFile(ctx context.Context, key string, r io.Reader, size, partSize int64) error {
+	if size <= partSize {
+		_, err := s.putStream(ctx, key, r, size, "", nil)
+		return err
+	}
+	uploader := NewS3Uploader(s, func(options *S3UploaderOptions) {
+		options.PartSize = fitPartSize(size, partSize)
+		options.Concurrency = 1
+	})
+	_, err := uploader.Upload(ctx, key, r)
+	return err
+}
+
+// SyncDown downloads changed objects under prefix into dir.
+// Objects are downloaded as stored without decompression, as sizes and ETags are compared with stored bytes.
+func (s *S3Bucket) SyncDown(ctx context.Context, prefix, dir string, optFns ...func(options *S3SyncOptions)) (*S3SyncPlan, error) {
+	options := newS3SyncOptions(optFns...)
+	fsys := os.DirFS(dir)
+	var files []*localFile
+	// dir is created after planning, so that a dry run doesn't change anything
+	_, err := os.Stat(dir)
+	if err == nil {
+		files, err = walkSyncFiles(fsys, options)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s.listSyncObjects(ctx, prefix, options)
+	if err != nil {
+		return nil, err
+	}
+
+	localFiles := make(map[string]*localFile, len(files))
+	for _, f := range files {
+		localFiles[f.path] = f
+	}
+
+	plan := &S3SyncPlan{}
+	for _, key := range sortedKeys(objects) {
+		p := strings.TrimPrefix(key, prefix)
+		if !fs.ValidPath(p) {
+			continue
+		}
+		reason, err := downloadReason(fsys, localFiles[p], objects[key], options.Compare)
+		if err != nil {
+			return nil, err
+		}
+		delete(localFiles, p)
+		if reason != "" {
+			plan.Ops = append(plan.Ops, &S3SyncOp{Action: SyncDownload, Path: p, Key: key, Size: objects[key].Size, Reason: reason})
+		}
+	}
+	if options.Delete {
+		for _, p := range sortedKeys(localFiles) {
+			plan.Ops = append(plan.Ops, &S3SyncOp{Action: SyncDelete, Path: p, Size: localFiles[p].info.Size(), Reason: "extraneous"})
+		}
+	}
+	if options.DryRun {
+		return plan, nil
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return plan, fmt.Errorf("mkdir: %w", err)
+	}
+
+	err = parallel(ctx, options.Concurrency, len(plan.Ops), func(ctx context.Context, i int) error {
+		op := plan.Ops[i]
+		name := filepath.Join(dir, filepath.FromSlash(op.Path))
+		if op.Action == SyncDelete {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove %s: %w", op.Path, err)
+			}
+			return nil
+		}
+		if err := s.downloadFile(ctx, op.Key, name); err != nil {
+			return fmt.Errorf("download %s: %w", op.Key, err)
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// downloadFile writes the object into a temporary file, then renames it to name
+func (s *S3Bucket) downloadFile(ctx context.Context, key, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	body, info, err := s.GetRawStream(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".sync-*")
+	if err != nil {
+		return fmt.Errorf("create temp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if err = os.Chtimes(f.Name(), info.LastModified, info.LastModified); err != nil {
+		return fmt.Errorf("chtimes: %w", err)
+	}
+	if err = os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	return nil
+}
+
+func newS3SyncOptions(optFns ...func(options *S3SyncOptions)) *S3SyncOptions {
+	options := &S3SyncOptions{
+		Compare:     SyncCompareETag,
+		Concurrency: defaultSyncConcurrency,
+		PartSize:    defaultUploadPartSize,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.PartSize < minPartSize {
+		options.PartSize = minPartSize
+	}
+	return options
+}
+
+func (o *S3SyncOptions) match(p string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(p)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(o.Include) > 0 && !matchAny(o.Include) {
+		return false
+	}
+	return !matchAny(o.Exclude)
+}
+
+func walkSyncFiles(fsys fs.FS, options *S3SyncOptions) ([]*localFile, error) {
+	var files []*localFile
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !options.match(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, &localFile{path: p, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	return files, nil
+}
+
+func (s *S3Bucket) listSyncObjects(ctx context.Context, prefix string, options *S3SyncOptions) (map[string]*S3ObjectInfo, error) {
+	objects, _, err := s.List(ctx, prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*S3ObjectInfo, len(objects))
+	for _, obj := range objects {
+		p := strings.TrimPrefix(obj.Key, prefix)
+		// skip "folder" objects
+		if p == "" || strings.HasSuffix(p, "/") || !options.match(p) {
+			continue
+		}
+		m[obj.Key] = obj
+	}
+	return m, nil
+}
+
+func uploadReason(fsys fs.FS, f *localFile, obj *S3ObjectInfo, compare S3SyncCompare) (string, error) {
+	if obj == nil {
+		return "new", nil
+	}
+	if f.info.Size() != obj.Size {
+		return "size", nil
+	}
+	switch compare {
+	case SyncCompareModTime:
+		if f.info.ModTime().After(obj.LastModified) {
+			return "modtime", nil
+		}
+	case SyncCompareETag:
+		return etagReason(fsys, f.path, obj)
+	}
+	return "", nil
+}
+
+func downloadReason(fsys fs.FS, f *localFile, obj *S3ObjectInfo, compare S3SyncCompare) (string, error) {
+	if f == nil {
+		return "new", nil
+	}
+	if f.info.Size() != obj.Size {
+		return "size", nil
+	}
+	switch compare {
+	case SyncCompareModTime:
+		if obj.LastModified.After(f.info.ModTime()) {
+			return "modtime", nil
+		}
+	case SyncCompareETag:
+		return etagReason(fsys, f.path, obj)
+	}
+	return "", nil
+}
+
+func etagReason(fsys fs.FS, p string, obj *S3ObjectInfo) (string, error) {
+	etag := strings.Trim(obj.ETag, `"`)
+	if strings.Contains(etag, "-") {
+		return "", nil
+	}
+	sum, err := fileMD5(fsys, p)
+	if err != nil {
+		return "", err
+	}
+	if sum != etag {
+		return "etag", nil
+	}
+	return "", nil
+}
+
+func fileMD5(fsys fs.FS, p string) (string, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", p, err)
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read %s: %w", p, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,397 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.olapie.com/x/xconv"
+)
+
+const (
+	defaultUploadPartSize    = 8 << 20
+	defaultUploadConcurrency = 4
+	defaultUploadPartRetries = 3
+
+	// maxUploadParts is the maximum number of parts in a multipart upload
+	maxUploadParts = 10000
+)
+
+type S3UploaderOptions struct {
+	// PartSize is the size of each part except the last one. It can't be less than 5MB
+	PartSize int64
+
+	// Concurrency is the number of parts uploaded in parallel
+	Concurrency int
+
+	// MaxRetries is the number of retries of a failed part
+	MaxRetries int
+
+	// ContentType is detected by key's extension if it's empty
+	ContentType string
+
+	Metadata map[string]string
+
+	// LeavePartsOnError keeps the upload rather than aborting it on failure, so that it can be resumed
+	LeavePartsOnError bool
+}
+
+type S3UploadResult struct {
+	ETag string
+
+	// UploadID is empty if content is uploaded in a single request
+	UploadID string
+	Parts    int
+}
+
+// S3UploadError is returned if an upload fails.
+// UploadID can be used to resume the upload if S3UploaderOptions.LeavePartsOnError is true.
+type S3UploadError struct {
+	UploadID string
+	Aborted  bool
+
+	err error
+}
+
+func (e *S3UploadError) Error() string {
+	return fmt.Sprintf("upload %s: %v", e.UploadID, e.err)
+}
+
+func (e *S3UploadError) Unwrap() error {
+	return e.err
+}
+
+// S3Uploader uploads large content in concurrent parts
+type S3Uploader struct {
+	bucket  *S3Bucket
+	options *S3UploaderOptions
+}
+
+func NewS3Uploader(bucket *S3Bucket, optFns ...func(options *S3UploaderOptions)) *S3Uploader {
+	u := &S3Uploader{
+		bucket: bucket,
+		options: &S3UploaderOptions{
+			PartSize:    defaultUploadPartSize,
+			Concurrency: defaultUploadConcurrency,
+			MaxRetries:  defaultUploadPartRetries,
+		},
+	}
+
+	for _, fn := range optFns {
+		fn(u.options)
+	}
+
+	if u.options.PartSize < minPartSize {
+		u.options.PartSize = minPartSize
+	}
+
+	if u.options.Concurrency <= 0 {
+		u.options.Concurrency = 1
+	}
+
+	if u.options.MaxRetries < 0 {
+		u.options.MaxRetries = 0
+	}
+	return u
+}
+
+// Upload uploads content read from r to key.
+// Content smaller than a part is uploaded with a single request.
+func (u *S3Uploader) Upload(ctx context.Context, key string, r io.Reader, optFns ...func(*s3.CreateMultipartUploadInput)) (*S3UploadResult, error) {
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(u.bucket.bucket),
+		Key:          aws.String(key),
+		ACL:          u.bucket.ACL,
+		CacheControl: aws.String(u.bucket.CacheControl),
+		ContentType:  aws.String(contentTypeOf(key, u.options.ContentType)),
+		Metadata:     u.options.Metadata,
+	}
+	for _, fn := range optFns {
+		fn(createInput)
+	}
+
+	first := make([]byte, u.options.PartSize)
+	n, err := io.ReadFull(r, first)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		putInput := putObjectInputFromMultipart(createInput)
+		putInput.Body = bytes.NewReader(first[:n])
+		putInput.ContentLength = aws.Int64(int64(n))
+		output, err := u.bucket.client.PutObject(ctx, putInput)
+		if err != nil {
+			return nil, fmt.Errorf("s3.PutObject: %w", err)
+		}
+		return &S3UploadResult{ETag: xconv.Dereference(output.ETag), Parts: 1}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	output, err := u.bucket.client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return nil, fmt.Errorf("s3.CreateMultipartUpload: %w", err)
+	}
+	uploadID := xconv.Dereference(output.UploadId)
+	return u.upload(ctx, key, uploadID, io.MultiReader(bytes.NewReader(first), r), nil)
+}
+
+// Resume continues the upload identified by uploadID.
+// r must provide the whole content from the beginning. Parts which have been uploaded with the same content are reused.
+func (u *S3Uploader) Resume(ctx context.Context, key, uploadID string, r io.Reader) (*S3UploadResult, error) {
+	uploaded, err := u.bucket.listAllParts(ctx, key, uploadID)
+	if err != nil {
+		return nil, &S3UploadError{UploadID: uploadID, err: fmt.Errorf("listAllParts: %w", err)}
+	}
+
+	done := make(map[int32]types.Part, len(uploaded))
+	for _, p := range uploaded {
+		// only complete parts of the same size can be reused, the last part is always uploaded again
+		if xconv.Dereference(p.Size) == u.options.PartSize {
+			done[xconv.Dereference(p.PartNumber)] = p
+		}
+	}
+	return u.upload(ctx, key, uploadID, r, done)
+}
+
+// fitPartSize returns partSize, or the smallest part size which fits size bytes in maxUploadParts parts if it's larger
+func fitPartSize(size, partSize int64) int64 {
+	return max(partSize, (size+maxUploadParts-1)/maxUploadParts)
+}
+
+type uploadPart struct {
+	number int32
+	data   []byte
+}
+
+func (u *S3Uploader) upload(ctx context.Context, key, uploadID string, r io.Reader, done map[int32]types.Part) (*S3UploadResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		parts    []types.CompletedPart
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	// buffers limits memory to Concurrency + 1 parts
+	buffers := make(chan []byte, u.options.Concurrency+1)
+	for i := 0; i < cap(buffers); i++ {
+		buffers <- make([]byte, u.options.PartSize)
+	}
+
+	partC := make(chan *uploadPart)
+	var wg sync.WaitGroup
+	for i := 0; i < u.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range partC {
+				etag, err := u.uploadPart(ctx, key, uploadID, p)
+				buffers <- p.data[:cap(p.data)]
+				if err != nil {
+					setErr(fmt.Errorf("part %d: %w", p.number, err))
+					continue
+				}
+				mu.Lock()
+				parts = append(parts, types.CompletedPart{
+					ETag:       aws.String(etag),
+					PartNumber: aws.Int32(p.number),
+				})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var readErr error
+	for number := int32(1); ctx.Err() == nil; number++ {
+		if number > maxUploadParts {
+			readErr = fmt.Errorf("content exceeds %d parts of %d bytes", maxUploadParts, u.options.PartSize)
+			break
+		}
+
+		var buf []byte
+		select {
+		case buf = <-buffers:
+		case <-ctx.Done():
+		}
+		if buf == nil {
+			break
+		}
+
+		n, err := io.ReadFull(r, buf)
+		if p, ok := done[number]; ok && isUploadedPart(p, buf[:n]) {
+			buffers <- buf
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{
+				ETag:       p.ETag,
+				PartNumber: aws.Int32(number),
+			})
+			mu.Unlock()
+		} else if n > 0 || number == 1 {
+			partC <- &uploadPart{number: number, data: buf[:n]}
+		} else {
+			buffers <- buf
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("read: %w", err)
+			break
+		}
+	}
+	close(partC)
+	wg.Wait()
+
+	if readErr != nil {
+		setErr(readErr)
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		// parent context is done
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, u.abort(ctx, key, uploadID, firstErr)
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+	etag, err := u.bucket.CompleteMultipartUpload(ctx, key, uploadID, parts)
+	if err != nil {
+		return nil, u.abort(ctx, key, uploadID, fmt.Errorf("completeMultipartUpload: %w", err))
+	}
+	return &S3UploadResult{
+		ETag:     etag,
+		UploadID: uploadID,
+		Parts:    len(parts),
+	}, nil
+}
+
+func (u *S3Uploader) uploadPart(ctx context.Context, key, uploadID string, p *uploadPart) (string, error) {
+	backoff := 200 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		output, err := u.bucket.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(u.bucket.bucket),
+			Key:           aws.String(key),
+			PartNumber:    aws.Int32(p.number),
+			UploadId:      aws.String(uploadID),
+			Body:          bytes.NewReader(p.data),
+			ContentLength: aws.Int64(int64(len(p.data))),
+		})
+		if err == nil {
+			return xconv.Dereference(output.ETag), nil
+		}
+		if attempt >= u.options.MaxRetries || ctx.Err() != nil {
+			return "", fmt.Errorf("s3.UploadPart: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (u *S3Uploader) abort(ctx context.Context, key, uploadID string, cause error) error {
+	e := &S3UploadError{
+		UploadID: uploadID,
+		err:      cause,
+	}
+	if u.options.LeavePartsOnError {
+		return e
+	}
+	if err := u.bucket.AbortMultipartUpload(context.WithoutCancel(ctx), key, uploadID); err != nil {
+		e.err = errors.Join(cause, fmt.Errorf("abortMultipartUpload: %w", err))
+		return e
+	}
+	e.Aborted = true
+	return e
+}
+
+// listAllParts lists parts of an upload page by page
+func (s *S3Bucket) listAllParts(ctx context.Context, key, uploadID string) ([]types.Part, error) {
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}
+	var parts []types.Part
+	paginator := s3.NewListPartsPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("s3.ListParts: %w", err)
+		}
+		parts = append(parts, output.Parts...)
+	}
+	return parts, nil
+}
+
+// isUploadedPart reports whether p has been uploaded with data.
+// ETag of a part is the MD5 of its content unless it's encrypted by SSE-KMS or SSE-C, in which case the part is uploaded again.
+func isUploadedPart(p types.Part, data []byte) bool {
+	if xconv.Dereference(p.Size) != int64(len(data)) {
+		return false
+	}
+	sum := md5.Sum(data)
+	return md5OfETag(xconv.Dereference(p.ETag)) == base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func putObjectInputFromMultipart(in *s3.CreateMultipartUploadInput) *s3.PutObjectInput {
+	return &s3.PutObjectInput{
+		Bucket:               in.Bucket,
+		Key:                  in.Key,
+		ACL:                  in.ACL,
+		CacheControl:         in.CacheControl,
+		ChecksumAlgorithm:    in.ChecksumAlgorithm,
+		ContentDisposition:   in.ContentDisposition,
+		ContentEncoding:      in.ContentEncoding,
+		ContentLanguage:      in.ContentLanguage,
+		ContentType:          in.ContentType,
+		Expires:              in.Expires,
+		Metadata:             in.Metadata,
+		ServerSideEncryption: in.ServerSideEncryption,
+		SSEKMSKeyId:          in.SSEKMSKeyId,
+		StorageClass:         in.StorageClass,
+		Tagging:              in.Tagging,
+	}
+}
+
+func multipartInputFromPutObject(in *s3.PutObjectInput) *s3.CreateMultipartUploadInput {
+	return &s3.CreateMultipartUploadInput{
+		Bucket:               in.Bucket,
+		Key:                  in.Key,
+		ACL:                  in.ACL,
+		CacheControl:         in.CacheControl,
+		ChecksumAlgorithm:    in.ChecksumAlgorithm,
+		ContentDisposition:   in.ContentDisposition,
+		ContentEncoding:      in.ContentEncoding,
+		ContentLanguage:      in.ContentLanguage,
+		ContentType:          in.ContentType,
+		Expires:              in.Expires,
+		Metadata:             in.Metadata,
+		ServerSideEncryption: in.ServerSideEncryption,
+		SSEKMSKeyId:          in.SSEKMSKeyId,
+		StorageClass:         in.StorageClass,
+		Tagging:              in.Tagging,
+	}
+}
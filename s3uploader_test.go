@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Uploader_Upload(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	key := uuid.NewString()
+	content := make([]byte, 12<<20)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+
+	uploader := NewS3Uploader(bucket, func(options *S3UploaderOptions) {
+		options.PartSize = 5 << 20
+	})
+	result, err := uploader.Upload(ctx, key, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Parts)
+	defer bucket.Delete(ctx, key)
+
+	readContent, err := bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, content, readContent)
+}
+
+func TestS3Uploader_Resume(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{}
+	bucket := newFakeS3Bucket(t, fake)
+	content := make([]byte, 2*minPartSize+minPartSize/2)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	other := make([]byte, minPartSize)
+	_, err = rand.Read(other)
+	require.NoError(t, err)
+
+	// part 1 has the same content, part 2 belongs to other content, part 3 is the short last part
+	key := "resume"
+	uploadID, err := bucket.CreateMultipartUpload(ctx, key)
+	require.NoError(t, err)
+	_, err = bucket.UploadPart(ctx, key, uploadID, 1, content[:minPartSize])
+	require.NoError(t, err)
+	_, err = bucket.UploadPart(ctx, key, uploadID, 2, other)
+	require.NoError(t, err)
+	_, err = bucket.UploadPart(ctx, key, uploadID, 3, content[2*minPartSize:])
+	require.NoError(t, err)
+
+	uploader := NewS3Uploader(bucket, func(options *S3UploaderOptions) {
+		options.PartSize = minPartSize
+	})
+	result, err := uploader.Resume(ctx, key, uploadID, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Parts)
+	readContent, err := bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, content, readContent)
+	uploads := fake.requestsWithPrefix("PUT " + key + "?")
+	require.Len(t, uploads, 5)
+	require.Contains(t, uploads[3], "partNumber=2")
+	require.Contains(t, uploads[4], "partNumber=3")
+
+	// parts of a different size are uploaded again
+	uploadID, err = bucket.CreateMultipartUpload(ctx, key)
+	require.NoError(t, err)
+	_, err = bucket.UploadPart(ctx, key, uploadID, 1, content[:minPartSize])
+	require.NoError(t, err)
+	uploader = NewS3Uploader(bucket, func(options *S3UploaderOptions) {
+		options.PartSize = minPartSize + 1
+	})
+	result, err = uploader.Resume(ctx, key, uploadID, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Parts)
+	readContent, err = bucket.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, content, readContent)
+	require.Len(t, fake.requestsWithPrefix("PUT "+key+"?"), 9)
+}
+
+func TestFitPartSize(t *testing.T) {
+	require.Equal(t, int64(defaultCopyPartSize), fitPartSize(6<<30, defaultCopyPartSize))
+	require.Equal(t, int64(minPartSize), fitPartSize(minPartSize*maxUploadParts, minPartSize))
+	for _, size := range []int64{minPartSize*maxUploadParts + 1, 50 << 30, 5 << 40} {
+		partSize := fitPartSize(size, minPartSize)
+		require.Greater(t, partSize, int64(minPartSize))
+		require.LessOrEqual(t, (size+partSize-1)/partSize, int64(maxUploadParts))
+	}
+}
@@ -0,0 +1,166 @@
+package aws
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const defaultZipConcurrency = 4
+
+// S3ZipEntry is an object to be added to a zip archive
+type S3ZipEntry struct {
+	Key string
+
+	// Name is the path in the archive, e.g. "attachments/a.pdf". Key is used if it's empty.
+	Name string
+}
+
+type S3ZipOptions struct {
+	// Concurrency is the number of objects fetched ahead of the one being written.
+	// Only response bodies are held open, content is never buffered entirely.
+	Concurrency int
+
+	// Method is zip.Deflate by default, zip.Store avoids compressing content that's already compressed, e.g. images
+	Method uint16
+
+	// Comment is the comment of the archive
+	Comment string
+}
+
+type zipFetch struct {
+	body io.ReadCloser
+	info *S3ObjectInfo
+	err  error
+}
+
+// WriteZip streams a zip archive of entries to w. Entries are written in order.
+func (s *S3Bucket) WriteZip(ctx context.Context, w io.Writer, entries []*S3ZipEntry, optFns ...func(options *S3ZipOptions)) error {
+	options := &S3ZipOptions{
+		Concurrency: defaultZipConcurrency,
+		Method:      zip.Deflate,
+	}
+	for _, fn := range optFns {
+		fn(options)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		name := zipEntryName(e)
+		if name == "" {
+			return fmt.Errorf("missing name of entry")
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate entry %s", name)
+		}
+		names[name] = true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// each result is buffered, so that a fetch never blocks after the archive is abandoned
+	results := make([]chan *zipFetch, len(entries))
+	for i := range results {
+		results[i] = make(chan *zipFetch, 1)
+	}
+	tokens := make(chan struct{}, options.Concurrency)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, e := range entries {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				body, info, err := s.GetStream(ctx, e.Key)
+				results[i] <- &zipFetch{body: body, info: info, err: err}
+			}()
+		}
+	}()
+
+	next := 0
+	defer func() {
+		// close bodies fetched ahead of a failure
+		cancel()
+		wg.Wait()
+		for _, ch := range results[next:] {
+			select {
+			case f := <-ch:
+				if f.body != nil {
+					f.body.Close()
+				}
+			default:
+			}
+		}
+	}()
+
+	zw := zip.NewWriter(w)
+	if options.Comment != "" {
+		if err := zw.SetComment(options.Comment); err != nil {
+			return fmt.Errorf("setComment: %w", err)
+		}
+	}
+	for ; next < len(entries); next++ {
+		var f *zipFetch
+		select {
+		case f = <-results[next]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if f.err != nil {
+			return fmt.Errorf("get %s: %w", entries[next].Key, f.err)
+		}
+		err := writeZipEntry(zw, zipEntryName(entries[next]), f, options.Method)
+		f.body.Close()
+		<-tokens
+		if err != nil {
+			return fmt.Errorf("write %s: %w", entries[next].Key, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	return nil
+}
+
+// PutZip streams a zip archive of entries to a new object of key, and returns its ETag
+func (s *S3Bucket) PutZip(ctx context.Context, key string, entries []*S3ZipEntry, optFns ...func(options *S3ZipOptions)) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.WriteZip(ctx, pw, entries, optFns...))
+	}()
+	defer pr.Close()
+	return s.putStream(ctx, key, pr, -1, "application/zip", nil)
+}
+
+func writeZipEntry(zw *zip.Writer, name string, f *zipFetch, method uint16) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: f.info.LastModified,
+	}
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f.body)
+	return err
+}
+
+func zipEntryName(e *S3ZipEntry) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.Key
+}
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3_Zip(t *testing.T) {
+	bucket := setupS3Bucket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	prefix := uuid.NewString() + "/"
+	contents := map[string][]byte{
+		"attachments/a.txt": []byte("a " + uuid.NewString()),
+		"attachments/b.txt": []byte("b " + uuid.NewString()),
+	}
+	var entries []*S3ZipEntry
+	for _, name := range sortedKeys(contents) {
+		key := prefix + uuid.NewString()
+		_, err := bucket.Put(ctx, key, contents[name], nil)
+		require.NoError(t, err)
+		defer bucket.Delete(ctx, key)
+		entries = append(entries, &S3ZipEntry{Key: key, Name: name})
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, bucket.WriteZip(ctx, &buf, entries, func(options *S3ZipOptions) {
+		options.Concurrency = 1
+	}))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, len(contents))
+	for _, f := range zr.File {
+		data, err := fs.ReadFile(zr, f.Name)
+		require.NoError(t, err)
+		require.Equal(t, contents[f.Name], data)
+	}
+
+	err = bucket.WriteZip(ctx, io.Discard, append(entries, &S3ZipEntry{Key: prefix + "missing", Name: "missing.txt"}))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	key := prefix + "all.zip"
+	_, err = bucket.PutZip(ctx, key, entries)
+	require.NoError(t, err)
+	defer bucket.Delete(ctx, key)
+	data, err := bucket.Get(ctx, key)
+	require.NoError(t, err)
+	zr, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, len(contents))
+}